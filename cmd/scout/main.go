@@ -1,36 +1,84 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"mime"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/a-h/templ"
 	"github.com/gfxv/scout/internal/config"
+	"github.com/gfxv/scout/internal/database"
 	"github.com/gfxv/scout/internal/engine"
 	"github.com/gfxv/scout/views"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/adaptor"
 )
 
+// maxCompletions is the number of suggestions returned by /autocomplete.
+const maxCompletions = 8
+
 func main() {
-	cfg := config.ParseConfig()
+	cfg, err := config.ParseConfig()
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
 	if err := config.ValidateConfig(&cfg); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "Usage: scout [command] [flags]\n")
+		fmt.Fprintf(os.Stderr, "Commands:\n")
+		fmt.Fprintf(os.Stderr, "  migrate\t\tapply pending database schema migrations\n")
+		fmt.Fprintf(os.Stderr, "  config validate\tcheck the configuration and exit\n")
+		fmt.Fprintf(os.Stderr, "  reindex -analyzer-only\trebuild the terms from the stored text with the current analyzers\n")
+		fmt.Fprintf(os.Stderr, "  duplicates\t\treport groups of duplicate and near-duplicate documents\n")
+		fmt.Fprintf(os.Stderr, "  no-text\t\treport files no text (or not all pages) could be extracted from\n")
+		fmt.Fprintf(os.Stderr, "Flags:\n")
 		flag.PrintDefaults()
 		os.Exit(1)
 	}
 
-	indexer := engine.NewIndexer(cfg.DBPath)
-	var err error
+	switch cfg.Command {
+	case config.CommandMigrate:
+		if err := runMigrate(&cfg); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		return
+	case config.CommandConfigValidate:
+		if err := runConfigValidate(&cfg); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		return
+	}
+
+	store, err := database.OpenWithOptions(cfg.DBPath, sqliteOptions(&cfg))
+	if err != nil {
+		log.Fatalf("Error: failed to open database: %v", err)
+	}
+	indexer, err := engine.NewIndexerWithOptions(store, engineOptions(&cfg))
+	if err != nil {
+		store.Close()
+		log.Fatalf("Error: %v", err)
+	}
+	defer indexer.Close()
+
 	switch {
+	case cfg.Command == config.CommandReindex:
+		err = runReindex(indexer)
+	case cfg.Command == config.CommandDuplicates:
+		err = runDuplicates(indexer)
+	case cfg.Command == config.CommandNoText:
+		err = runNoText(indexer)
 	case cfg.Index:
-		err = runIndexer(indexer, cfg.Files)
+		err = runIndexer(indexer, indexSources(&cfg))
 	case cfg.Serve:
-		err = runServer(indexer, cfg.Port)
+		err = runServer(indexer, cfg.Host+":"+cfg.Port)
 	}
 
 	if err != nil {
@@ -39,33 +87,325 @@ func main() {
 
 }
 
-func runIndexer(indexer *engine.Indexer, dir string) error {
+func sqliteOptions(cfg *config.Config) database.SQLiteOptions {
+	return database.SQLiteOptions{
+		JournalMode: cfg.SQLiteJournalMode,
+		Synchronous: cfg.SQLiteSynchronous,
+		CacheSize:   cfg.SQLiteCacheSize,
+		MmapSize:    cfg.SQLiteMmapSize,
+		BusyTimeout: cfg.SQLiteBusyTimeout,
+	}
+}
+
+func engineOptions(cfg *config.Config) engine.Options {
+	opts := engine.Options{
+		Language:    cfg.Language,
+		MaxResults:  cfg.MaxResults,
+		MinScore:    float32(cfg.MinScore),
+		Extensions:  cfg.Extensions,
+		PDFFallback: cfg.PDFFallback,
+		Commands:    cfg.Commands,
+		MaxFileSize: cfg.MaxFileSize,
+		StoreText:   cfg.StoreText,
+		Archives:    cfg.Archives,
+		Fuzzy:       cfg.Fuzzy,
+
+		CommandTimeout:      cfg.CommandTimeout,
+		ArchiveMaxSize:      cfg.ArchiveMaxSize,
+		ArchiveMaxEntrySize: cfg.ArchiveMaxEntrySize,
+		CommandMaxOutput:    cfg.CommandMaxOutput,
+		Passages:            cfg.Passages,
+		PassageWords:        cfg.PassageWords,
+		CollapseDuplicates:  cfg.CollapseDuplicates,
+
+		SynonymsFile:  cfg.SynonymsFile,
+		SynonymWeight: float32(cfg.SynonymWeight),
+
+		Analyzer:            engine.AnalyzerConfig(cfg.Analyzer),
+		CollectionAnalyzers: make(map[string]engine.AnalyzerConfig, len(cfg.Analyzers)),
+	}
+	for collection, analyzer := range cfg.Analyzers {
+		opts.CollectionAnalyzers[collection] = engine.AnalyzerConfig(analyzer)
+	}
+	return opts
+}
+
+// indexSources returns the -files directory if given, otherwise the configured sources.
+func indexSources(cfg *config.Config) []engine.Source {
+	if cfg.Files != "" {
+		return []engine.Source{{Collection: cfg.Collection, Root: cfg.Files}}
+	}
+	sources := make([]engine.Source, 0, len(cfg.Sources))
+	for _, source := range cfg.Sources {
+		sources = append(sources, engine.Source{
+			Collection: source.Collection,
+			Root:       source.Path,
+			Include:    source.Include,
+			Exclude:    source.Exclude,
+		})
+	}
+	return sources
+}
+
+func runConfigValidate(cfg *config.Config) error {
+	errs := make([]error, 0)
+	if !database.IsPostgresDSN(cfg.DBPath) {
+		if err := sqliteOptions(cfg).Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := engineOptions(cfg).Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := engineOptions(cfg).CheckCommands(); err != nil {
+		errs = append(errs, err)
+	}
+	for _, source := range indexSources(cfg) {
+		if err := source.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "  - %v\n", err)
+		}
+		return fmt.Errorf("found %d problem(s) in the configuration", len(errs))
+	}
+
+	fmt.Printf("Configuration is valid (config file: %s)\n", cfg.ConfigPath)
+	for _, source := range indexSources(cfg) {
+		fmt.Printf("  source %s -> collection %s\n", source.Root, source.Collection)
+	}
+	return nil
+}
+
+func runMigrate(cfg *config.Config) error {
+	from, to, err := database.MigrateWithOptions(cfg.DBPath, sqliteOptions(cfg))
+	if err != nil {
+		return fmt.Errorf("migration failed: %v", err)
+	}
+	if from == to {
+		fmt.Printf("Schema is up to date (version %d)\n", to)
+		return nil
+	}
+	fmt.Printf("Migrated schema from version %d to %d\n", from, to)
+	return nil
+}
+
+func runIndexer(indexer *engine.Indexer, sources []engine.Source) error {
 	start := time.Now()
-	if err := indexer.IndexDir(dir); err != nil {
-		return fmt.Errorf("indexing failed: %v", err)
+	for _, source := range sources {
+		if err := indexer.IndexSource(source); err != nil {
+			return fmt.Errorf("indexing %s failed: %v", source.Root, err)
+		}
 	}
 	fmt.Printf("Indexing took: %s\n", time.Since(start))
 	return nil
 }
 
-func runServer(indexer *engine.Indexer, port string) error {
+func runReindex(indexer *engine.Indexer) error {
+	start := time.Now()
+	fromFiles, err := indexer.Reanalyze()
+	if err != nil {
+		return fmt.Errorf("reindexing failed: %v", err)
+	}
+	if fromFiles > 0 {
+		fmt.Printf("%d document(s) had no stored text and were read from their files, index with -store-text to avoid this\n", fromFiles)
+	}
+	fmt.Printf("Reindexing took: %s\n", time.Since(start))
+	return nil
+}
+
+func runDuplicates(indexer *engine.Indexer) error {
+	if err := indexer.Load(); err != nil {
+		return fmt.Errorf("failed to load indexer: %v", err)
+	}
+	clusters := indexer.Duplicates()
+	if len(clusters) == 0 {
+		fmt.Println("No duplicates found")
+		return nil
+	}
+	for _, cluster := range clusters {
+		kind := "Near duplicates"
+		if cluster.Exact {
+			kind = "Exact duplicates"
+		}
+		fmt.Printf("%s (%d documents):\n", kind, len(cluster.Paths))
+		for _, path := range cluster.Paths {
+			fmt.Printf("  %s\n", path)
+		}
+	}
+	return nil
+}
+
+func runNoText(indexer *engine.Indexer) error {
+	if err := indexer.Load(); err != nil {
+		return fmt.Errorf("failed to load indexer: %v", err)
+	}
+	problems := indexer.ExtractionProblems()
+	if len(problems) == 0 {
+		fmt.Println("Text was extracted from all files")
+		return nil
+	}
+	for _, problem := range problems {
+		if problem.NoText {
+			fmt.Printf("no text\t%s\n", problem.Path)
+		} else {
+			fmt.Printf("%d page(s) failed\t%s\n", problem.FailedPages, problem.Path)
+		}
+	}
+	return nil
+}
+
+func runServer(indexer *engine.Indexer, listenAddr string) error {
 	if err := indexer.Load(); err != nil {
 		return fmt.Errorf("failed to load indexer: %v", err)
 	}
 
+	mismatches, err := indexer.AnalyzerMismatches()
+	if err != nil {
+		return err
+	}
+	for _, mismatch := range mismatches {
+		log.Printf("Warning: collection %s was indexed with different analyzer settings (%s) than the current ones (%s), "+
+			"searches may miss documents, run `scout reindex -analyzer-only`\n",
+			mismatch.Collection, mismatch.Indexed, mismatch.Current)
+	}
+
+	go reloadOnHangup(indexer)
+
 	app := fiber.New()
-	app.Get("/", adaptor.HTTPHandler(templ.Handler(views.IndexPage())))
+	app.Get("/", func(c *fiber.Ctx) error {
+		collections, err := indexer.Collections()
+		if err != nil {
+			return fmt.Errorf("failed to load collections: %v", err)
+		}
+		handler := adaptor.HTTPHandler(templ.Handler(views.IndexPage(collections)))
+		return handler(c)
+	})
 	app.Get("/search", func(c *fiber.Ctx) error {
 		query := c.Query("q")
-		log.Printf("Received query: %s\n", query)
-		results := indexer.SearchQuery(query)
-		handler := adaptor.HTTPHandler(templ.Handler(views.SearchResults(results)))
+		live := c.QueryBool("live")
+		if !live {
+			log.Printf("Received query: %s\n", query)
+		}
+		response := indexer.Search(query, queryCollections(c)...)
+		// searches sent while typing would fill the history with partial queries
+		if !live && response.HasMatches() {
+			indexer.RecordQuery(query)
+		}
+		handler := adaptor.HTTPHandler(templ.Handler(views.SearchResults(response, query)))
+		return handler(c)
+	})
+	app.Get("/autocomplete", func(c *fiber.Ctx) error {
+		completions := indexer.Autocomplete(c.Query("q"), maxCompletions, queryCollections(c)...)
+		if c.Get("HX-Request") == "" {
+			return c.JSON(completions)
+		}
+		handler := adaptor.HTTPHandler(templ.Handler(views.Completions(completions)))
+		return handler(c)
+	})
+	app.Get("/similar/:id", func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return fiber.ErrBadRequest
+		}
+		path, _, err := indexer.Document(id)
+		if err != nil {
+			return documentError(err)
+		}
+		results, err := indexer.Similar(id, queryCollections(c)...)
+		if err != nil {
+			return documentError(err)
+		}
+		handler := adaptor.HTTPHandler(templ.Handler(views.SimilarResults(path, results)))
+		return handler(c)
+	})
+	app.Get("/doc/:id", func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return fiber.ErrBadRequest
+		}
+		query := c.Query("q")
+		view, err := indexer.DocumentView(id, query)
+		if err != nil {
+			return documentError(err)
+		}
+		handler := adaptor.HTTPHandler(templ.Handler(views.DocumentPage(view, query)))
 		return handler(c)
 	})
+	app.Get("/doc/:id/raw", func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return fiber.ErrBadRequest
+		}
+		file, path, err := indexer.OpenDocumentFile(id)
+		if err != nil {
+			return documentError(err)
+		}
+		setRawHeaders(c, path)
+		// fasthttp closes the file once it's sent
+		return c.SendStream(file)
+	})
 
-	listenAddr := ":" + port
 	if err := app.Listen(listenAddr); err != nil {
 		return fmt.Errorf("server failed on %s: %v", listenAddr, err)
 	}
 	return nil
 }
+
+// setRawHeaders sets the content type of an original file served from
+// Scout's origin. Scripts in indexed files (HTML, SVG, XML) must not run
+// as Scout, so everything but PDFs, whose viewer refuses to run sandboxed,
+// is served sandboxed and browsers mustn't guess another type.
+func setRawHeaders(c *fiber.Ctx, path string) {
+	contentType := mime.TypeByExtension(filepath.Ext(path))
+	if contentType == "" {
+		contentType = fiber.MIMEOctetStream
+	}
+	c.Set(fiber.HeaderContentType, contentType)
+	c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+	if !strings.HasPrefix(contentType, "application/pdf") {
+		c.Set(fiber.HeaderContentSecurityPolicy, "sandbox")
+	}
+}
+
+// reloadOnHangup reloads the synonyms file whenever the process gets SIGHUP,
+// e.g. `kill -HUP <pid>`, so they can be changed without a restart.
+func reloadOnHangup(indexer *engine.Indexer) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	for range hangup {
+		if err := indexer.ReloadSynonyms(); err != nil {
+			log.Printf("Failed to reload synonyms, keeping the previous ones: %v\n", err)
+			continue
+		}
+		log.Printf("Reloaded synonyms\n")
+	}
+}
+
+// documentError maps the errors of the document routes to HTTP errors.
+func documentError(err error) error {
+	switch {
+	case errors.Is(err, engine.ErrDocumentNotFound), errors.Is(err, os.ErrNotExist):
+		return fiber.ErrNotFound
+	case errors.Is(err, engine.ErrOutsideRoots):
+		log.Printf("Refused to serve document: %v\n", err)
+		return fiber.ErrForbidden
+	default:
+		return fmt.Errorf("failed to load document: %v", err)
+	}
+}
+
+// queryCollections returns the non-empty ?collection= values,
+// none means search all collections.
+func queryCollections(c *fiber.Ctx) []string {
+	collections := make([]string, 0)
+	for _, value := range c.Context().QueryArgs().PeekMulti("collection") {
+		if len(value) > 0 {
+			collections = append(collections, string(value))
+		}
+	}
+	return collections
+}
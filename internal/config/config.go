@@ -3,53 +3,223 @@ package config
 import (
 	"flag"
 	"fmt"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/caarlos0/env/v11"
 	"github.com/gofiber/fiber/v2/log"
 	"github.com/joho/godotenv"
 )
 
-// TODO: add .env parsing
+// Commands that can be given before the flags, e.g. `scout migrate -db meta.db`.
+const (
+	CommandMigrate        = "migrate"
+	CommandConfigValidate = "config validate"
+	CommandReindex        = "reindex"
+	CommandDuplicates     = "duplicates"
+	CommandNoText         = "no-text"
+)
+
+var commands = []string{CommandMigrate, CommandConfigValidate, CommandReindex, CommandDuplicates, CommandNoText}
 
+// Config is assembled from, in increasing order of precedence: defaults,
+// the config file (scout.yaml), environment variables (and .env) and flags.
 type Config struct {
-	Index  bool   `env:"SCOUT_INDEX" envDefault:"false"`
-	Serve  bool   `env:"SCOUT_SERVE" envDefault:"false"`
-	Files  string `env:"SCOUT_FILES"`
-	Port   string `env:"SCOUT_PORT" envDefault:"6969"`
-	DBPath string `env:"SCOUT_DB_PATH" envDefault:"meta.db"`
+	Command    string
+	ConfigPath string `env:"SCOUT_CONFIG"`
+
+	Index bool `env:"SCOUT_INDEX"`
+	Serve bool `env:"SCOUT_SERVE"`
+	// AnalyzerOnly makes reindex rebuild the terms from the stored text
+	AnalyzerOnly bool
+	Files        string `env:"SCOUT_FILES"`
+	Collection   string `env:"SCOUT_COLLECTION"`
+	Host         string `env:"SCOUT_HOST"`
+	Port         string `env:"SCOUT_PORT"`
+	DBPath       string `env:"SCOUT_DB_PATH"`
+
+	SQLiteJournalMode string        `env:"SCOUT_SQLITE_JOURNAL_MODE"`
+	SQLiteSynchronous string        `env:"SCOUT_SQLITE_SYNCHRONOUS"`
+	SQLiteCacheSize   int           `env:"SCOUT_SQLITE_CACHE_SIZE"`
+	SQLiteMmapSize    int64         `env:"SCOUT_SQLITE_MMAP_SIZE"`
+	SQLiteBusyTimeout time.Duration `env:"SCOUT_SQLITE_BUSY_TIMEOUT"`
+
+	Language   string  `env:"SCOUT_LANGUAGE"`
+	MaxResults int     `env:"SCOUT_MAX_RESULTS"`
+	MinScore   float64 `env:"SCOUT_MIN_SCORE"`
+	Fuzzy      bool    `env:"SCOUT_FUZZY"`
+
+	CollapseDuplicates bool  `env:"SCOUT_COLLAPSE_DUPLICATES"`
+	MaxFileSize        int64 `env:"SCOUT_MAX_FILE_SIZE"`
+	StoreText          bool  `env:"SCOUT_STORE_TEXT"`
+	Archives           bool  `env:"SCOUT_ARCHIVES"`
+
+	ArchiveMaxEntrySize int64 `env:"SCOUT_ARCHIVE_MAX_ENTRY_SIZE"`
+	ArchiveMaxSize      int64 `env:"SCOUT_ARCHIVE_MAX_SIZE"`
+
+	Passages     string `env:"SCOUT_PASSAGES"`
+	PassageWords int    `env:"SCOUT_PASSAGE_WORDS"`
+
+	CommandTimeout   time.Duration `env:"SCOUT_COMMAND_TIMEOUT"`
+	CommandMaxOutput int64         `env:"SCOUT_COMMAND_MAX_OUTPUT"`
+
+	SynonymsFile  string  `env:"SCOUT_SYNONYMS"`
+	SynonymWeight float64 `env:"SCOUT_SYNONYM_WEIGHT"`
+
+	// only available in the config file
+	Extensions map[string]string
+	// Commands are external extractors by extension, see engine.Options
+	Commands map[string][]string
+	// PDFFallback is a command extracting text from PDFs, see engine.Options
+	PDFFallback []string
+	Sources     []Source
+	Analyzer    Analyzer
+	// Analyzers of specific collections, by collection name
+	Analyzers map[string]Analyzer
+}
+
+// Source is a directory to index into a collection, see engine.Source.
+type Source struct {
+	Collection string
+	Path       string
+	Include    []string
+	Exclude    []string
+}
+
+// Analyzer is the text analysis pipeline, see engine.AnalyzerConfig.
+type Analyzer struct {
+	CharFilters []string
+	Tokenizer   string
+	Filters     []string
+	Language    string
+	StopWords   []string
+	MinLength   int
+	MaxLength   int
+}
+
+func defaultConfig() Config {
+	return Config{
+		ConfigPath: defaultConfigPath,
+		Collection: "default",
+		Port:       "6969",
+		DBPath:     "meta.db",
+
+		SQLiteJournalMode: "WAL",
+		SQLiteSynchronous: "NORMAL",
+		SQLiteCacheSize:   -64000,
+		SQLiteMmapSize:    256 << 20,
+		SQLiteBusyTimeout: 5 * time.Second,
+
+		Language: "english",
+		Fuzzy:    true,
+
+		SynonymWeight: 0.5,
+	}
 }
 
-func ParseConfig() Config {
+func ParseConfig() (Config, error) {
 	if err := godotenv.Load(); err != nil {
 		log.Warnf("Failed to load .env file: %v", err)
 	}
+	return parseConfig(os.Args[1:], flag.CommandLine)
+}
+
+func parseConfig(args []string, fs *flag.FlagSet) (Config, error) {
+	cfg := defaultConfig()
+
+	// the config file has to be loaded before env and flags override it,
+	// so its path is looked up ahead of the regular parsing
+	path, explicit := configPath(args)
+	if err := loadFile(&cfg, path, explicit); err != nil {
+		return cfg, err
+	}
+	cfg.ConfigPath = path
 
-	var cfg Config
 	if err := env.Parse(&cfg); err != nil {
-		log.Warnf("Failed to parse environment variables: %v\n", err)
+		return cfg, fmt.Errorf("failed to parse environment variables: %w", err)
 	}
 
-	fmt.Printf("%v+\n", cfg)
+	fs.StringVar(&cfg.ConfigPath, "config", cfg.ConfigPath, "Path to the YAML config file")
+	fs.BoolVar(&cfg.Index, "index", cfg.Index, "Index files in the specified directory (or the configured sources) and exit")
+	fs.BoolVar(&cfg.Serve, "serve", cfg.Serve, "Start the search web server")
+	fs.BoolVar(&cfg.AnalyzerOnly, "analyzer-only", cfg.AnalyzerOnly, "With reindex, rebuild the terms from the stored text instead of reading the files")
+	fs.StringVar(&cfg.Files, "files", cfg.Files, "Directory path containing files to index, overrides the configured sources")
+	fs.StringVar(&cfg.Collection, "collection", cfg.Collection, "Collection to put indexed files into (used with -files)")
+	fs.StringVar(&cfg.Host, "host", cfg.Host, "Host/interface to listen on when serving (empty means all)")
+	fs.StringVar(&cfg.Port, "port", cfg.Port, "Port to listen on when serving (e.g., 8080)")
+	fs.StringVar(&cfg.DBPath, "db", cfg.DBPath, "Path to the SQLite database file or a postgres:// DSN")
+	fs.StringVar(&cfg.SQLiteJournalMode, "sqlite-journal-mode", cfg.SQLiteJournalMode, "SQLite journal mode (WAL, DELETE, TRUNCATE, PERSIST, MEMORY, OFF)")
+	fs.StringVar(&cfg.SQLiteSynchronous, "sqlite-synchronous", cfg.SQLiteSynchronous, "SQLite synchronous mode (OFF, NORMAL, FULL, EXTRA)")
+	fs.IntVar(&cfg.SQLiteCacheSize, "sqlite-cache-size", cfg.SQLiteCacheSize, "SQLite page cache size, in pages if positive, in KiB if negative")
+	fs.Int64Var(&cfg.SQLiteMmapSize, "sqlite-mmap-size", cfg.SQLiteMmapSize, "Max bytes of the SQLite database to memory-map (0 disables mmap)")
+	fs.DurationVar(&cfg.SQLiteBusyTimeout, "sqlite-busy-timeout", cfg.SQLiteBusyTimeout, "How long to wait for a locked SQLite database (e.g., 5s)")
+	fs.StringVar(&cfg.Language, "language", cfg.Language, "Stemming language used for indexing and searching")
+	fs.IntVar(&cfg.MaxResults, "max-results", cfg.MaxResults, "Max number of search results (0 means no limit)")
+	fs.Float64Var(&cfg.MinScore, "min-score", cfg.MinScore, "Drop search results ranked below this score")
+	fs.BoolVar(&cfg.Fuzzy, "fuzzy", cfg.Fuzzy, "Match misspelt search terms to similar indexed terms (-fuzzy=false to disable)")
+	fs.BoolVar(&cfg.CollapseDuplicates, "collapse-duplicates", cfg.CollapseDuplicates, "Show only the best ranked of duplicate documents in search results")
+	fs.StringVar(&cfg.SynonymsFile, "synonyms", cfg.SynonymsFile, "Path to a synonyms file expanding search terms, reloaded on SIGHUP")
+	fs.Float64Var(&cfg.SynonymWeight, "synonym-weight", cfg.SynonymWeight, "Weight of synonyms relative to the searched term (0 to 1)")
+	fs.Int64Var(&cfg.MaxFileSize, "max-file-size", cfg.MaxFileSize, "Skip files larger than this many bytes while indexing (0 means no limit)")
+	fs.BoolVar(&cfg.StoreText, "store-text", cfg.StoreText, "Store the compressed extracted text of indexed files in the database")
+	fs.BoolVar(&cfg.Archives, "archives", cfg.Archives, "Index the files in .zip, .tar.gz and .tgz archives")
+	fs.Int64Var(&cfg.ArchiveMaxEntrySize, "archive-max-entry-size", cfg.ArchiveMaxEntrySize, "Skip files in archives extracting to more bytes (0 means 64 MiB)")
+	fs.Int64Var(&cfg.ArchiveMaxSize, "archive-max-size", cfg.ArchiveMaxSize, "Stop indexing an archive once more bytes were extracted from it (0 means 1 GiB)")
+	fs.StringVar(&cfg.Passages, "passages", cfg.Passages, "Split files into passages while indexing: auto (PDF pages, Markdown sections, windows otherwise), windows or pages (PDFs only)")
+	fs.IntVar(&cfg.PassageWords, "passage-words", cfg.PassageWords, "Size of passages split by word count (0 means 200)")
+	fs.DurationVar(&cfg.CommandTimeout, "command-timeout", cfg.CommandTimeout, "Kill external extractor commands running longer (0 means 30s)")
+	fs.Int64Var(&cfg.CommandMaxOutput, "command-max-output", cfg.CommandMaxOutput, "Fail external extractor commands printing more bytes (0 means 64 MiB)")
 
-	flag.BoolVar(&cfg.Index, "index", cfg.Index, "Index files in the specified directory and exit (required with -files)")
-	flag.BoolVar(&cfg.Serve, "serve", cfg.Serve, "Start the search web server")
-	flag.StringVar(&cfg.Files, "files", cfg.Files, "Directory path containing files to index (required with -index)")
-	flag.StringVar(&cfg.Port, "port", cfg.Port, "Port to listen on when serving (e.g., 8080)")
-	flag.StringVar(&cfg.DBPath, "db", cfg.DBPath, "Path to the SQLite database file")
-	flag.Parse()
+	command := make([]string, 0)
+	for len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command = append(command, args[0])
+		args = args[1:]
+	}
+	cfg.Command = strings.Join(command, " ")
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
 
-	return cfg
+	return cfg, nil
 }
 
 func ValidateConfig(cfg *Config) error {
+	if cfg.AnalyzerOnly && cfg.Command != CommandReindex {
+		return fmt.Errorf("-analyzer-only can only be used with the %s command", CommandReindex)
+	}
+	if cfg.Command != "" {
+		return validateCommand(cfg)
+	}
 	if cfg.Index && cfg.Serve {
 		return fmt.Errorf("cannot use both -index and -serve together")
 	}
 	if !cfg.Index && !cfg.Serve {
 		return fmt.Errorf("must specify either -index or -serve")
 	}
-	if cfg.Index && cfg.Files == "" {
-		return fmt.Errorf("-files is required when using -index")
+	if cfg.Index && cfg.Files == "" && len(cfg.Sources) == 0 {
+		return fmt.Errorf("-files (or sources in the config file) is required when using -index")
+	}
+	return nil
+}
+
+func validateCommand(cfg *Config) error {
+	known := false
+	for _, command := range commands {
+		if cfg.Command == command {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return fmt.Errorf("unknown command %q (available: %s)", cfg.Command, strings.Join(commands, ", "))
+	}
+	if cfg.Index || cfg.Serve {
+		return fmt.Errorf("-index and -serve cannot be used with the %s command", cfg.Command)
+	}
+	// re-reading all files is what -index does
+	if cfg.Command == CommandReindex && !cfg.AnalyzerOnly {
+		return fmt.Errorf("%s needs -analyzer-only, use -index to read the files again", cfg.Command)
 	}
 	return nil
 }
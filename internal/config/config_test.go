@@ -0,0 +1,203 @@
+package config
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "scout.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func parseTestConfig(t *testing.T, args ...string) (Config, error) {
+	t.Helper()
+	fs := flag.NewFlagSet("scout", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return parseConfig(args, fs)
+}
+
+func TestParseConfig_Precedence(t *testing.T) {
+	path := writeConfigFile(t, `
+db: from-file.db
+server:
+  port: "7000"
+  host: 127.0.0.1
+tokenizer:
+  language: french
+ranking:
+  max_results: 20
+`)
+	t.Setenv("SCOUT_PORT", "8000")
+	t.Setenv("SCOUT_LANGUAGE", "spanish")
+
+	cfg, err := parseTestConfig(t, "-config", path, "-language", "russian")
+	if err != nil {
+		t.Fatalf("parseConfig failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		got      any
+		expected any
+	}{
+		{name: "Default", got: cfg.SQLiteJournalMode, expected: "WAL"},
+		{name: "File over default", got: cfg.DBPath, expected: "from-file.db"},
+		{name: "File only", got: cfg.Host, expected: "127.0.0.1"},
+		{name: "File int", got: cfg.MaxResults, expected: 20},
+		{name: "Env over file", got: cfg.Port, expected: "8000"},
+		{name: "Flag over env and file", got: cfg.Language, expected: "russian"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, tt.got)
+			}
+		})
+	}
+}
+
+func TestParseConfig_Sources(t *testing.T) {
+	path := writeConfigFile(t, `
+ranking:
+  synonyms: synonyms.txt
+sources:
+  - collection: specs
+    path: docs/specs
+    include: ["*.md"]
+    exclude: ["drafts"]
+  - path: /srv/papers
+`)
+
+	cfg, err := parseTestConfig(t, "-config="+path)
+	if err != nil {
+		t.Fatalf("parseConfig failed: %v", err)
+	}
+	if len(cfg.Sources) != 2 {
+		t.Fatalf("expected 2 sources, got %d", len(cfg.Sources))
+	}
+
+	specs := cfg.Sources[0]
+	if want := filepath.Join(filepath.Dir(path), "docs/specs"); specs.Path != want {
+		t.Errorf("expected relative path resolved to %q, got %q", want, specs.Path)
+	}
+	if specs.Collection != "specs" || len(specs.Include) != 1 || len(specs.Exclude) != 1 {
+		t.Errorf("unexpected source: %+v", specs)
+	}
+	papers := cfg.Sources[1]
+	if papers.Path != "/srv/papers" || papers.Collection != "default" {
+		t.Errorf("unexpected source: %+v", papers)
+	}
+	if want := filepath.Join(filepath.Dir(path), "synonyms.txt"); cfg.SynonymsFile != want {
+		t.Errorf("expected synonyms path resolved to %q, got %q", want, cfg.SynonymsFile)
+	}
+}
+
+func TestParseConfig_Commands(t *testing.T) {
+	path := writeConfigFile(t, `
+readers:
+  commands:
+    .rtf: ["unrtf", "--text", "{path}"]
+  command_timeout: 10s
+  command_max_output: 1048576
+`)
+
+	cfg, err := parseTestConfig(t, "-config="+path, "-command-timeout=1m")
+	if err != nil {
+		t.Fatalf("parseConfig failed: %v", err)
+	}
+	if command := strings.Join(cfg.Commands[".rtf"], " "); command != "unrtf --text {path}" {
+		t.Errorf("expected the .rtf command, got %q", command)
+	}
+	if cfg.CommandTimeout != time.Minute {
+		t.Errorf("expected the flag to override the timeout, got %v", cfg.CommandTimeout)
+	}
+	if cfg.CommandMaxOutput != 1<<20 {
+		t.Errorf("expected max output %d, got %d", 1<<20, cfg.CommandMaxOutput)
+	}
+}
+
+func TestParseConfig_Analyzers(t *testing.T) {
+	path := writeConfigFile(t, `
+analyzer:
+  filters: [lowercase, punctuation, stem]
+analyzers:
+  logs:
+    tokenizer: whitespace
+    filters: []
+    min_length: 2
+`)
+
+	cfg, err := parseTestConfig(t, "-config", path)
+	if err != nil {
+		t.Fatalf("parseConfig failed: %v", err)
+	}
+	if len(cfg.Analyzer.Filters) != 3 || cfg.Analyzer.Tokenizer != "" {
+		t.Errorf("unexpected default analyzer: %+v", cfg.Analyzer)
+	}
+	logs, ok := cfg.Analyzers["logs"]
+	if !ok || logs.Tokenizer != "whitespace" || logs.Filters == nil || len(logs.Filters) != 0 || logs.MinLength != 2 {
+		t.Errorf("unexpected logs analyzer: %+v", logs)
+	}
+}
+
+func TestParseConfig_Errors(t *testing.T) {
+	t.Run("Unknown field", func(t *testing.T) {
+		path := writeConfigFile(t, "servr:\n  port: \"7000\"\n")
+		if _, err := parseTestConfig(t, "-config", path); err == nil {
+			t.Error("expected error for unknown field")
+		}
+	})
+	t.Run("Missing explicit file", func(t *testing.T) {
+		if _, err := parseTestConfig(t, "-config", filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+			t.Error("expected error for missing config file")
+		}
+	})
+	t.Run("Missing default file", func(t *testing.T) {
+		if _, err := parseTestConfig(t); err != nil {
+			t.Errorf("expected missing default config file to be ignored, got %v", err)
+		}
+	})
+}
+
+func TestParseConfig_Command(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected string
+		valid    bool
+	}{
+		{args: []string{"migrate", "-db", "x.db"}, expected: CommandMigrate, valid: true},
+		{args: []string{"config", "validate"}, expected: CommandConfigValidate, valid: true},
+		{args: []string{"config"}, expected: "config", valid: false},
+		{args: []string{"reindex", "--analyzer-only"}, expected: CommandReindex, valid: true},
+		{args: []string{"reindex"}, expected: CommandReindex, valid: false},
+		{args: []string{"duplicates", "-db", "x.db"}, expected: CommandDuplicates, valid: true},
+		{args: []string{"no-text"}, expected: CommandNoText, valid: true},
+		{args: []string{"-serve", "-analyzer-only"}, expected: "", valid: false},
+		{args: []string{"-serve"}, expected: "", valid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			cfg, err := parseTestConfig(t, tt.args...)
+			if err != nil {
+				t.Fatalf("parseConfig failed: %v", err)
+			}
+			if cfg.Command != tt.expected {
+				t.Errorf("expected command %q, got %q", tt.expected, cfg.Command)
+			}
+			if err := ValidateConfig(&cfg); (err == nil) != tt.valid {
+				t.Errorf("expected valid = %v, got error %v", tt.valid, err)
+			}
+		})
+	}
+}
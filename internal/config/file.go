@@ -0,0 +1,205 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const defaultConfigPath = "scout.yaml"
+
+// fileConfig is the layout of scout.yaml, unset fields keep their defaults.
+type fileConfig struct {
+	DB *string `yaml:"db"`
+
+	SQLite struct {
+		JournalMode *string        `yaml:"journal_mode"`
+		Synchronous *string        `yaml:"synchronous"`
+		CacheSize   *int           `yaml:"cache_size"`
+		MmapSize    *int64         `yaml:"mmap_size"`
+		BusyTimeout *time.Duration `yaml:"busy_timeout"`
+	} `yaml:"sqlite"`
+
+	Server struct {
+		Host *string `yaml:"host"`
+		Port *string `yaml:"port"`
+	} `yaml:"server"`
+
+	Readers struct {
+		MaxFileSize      *int64              `yaml:"max_file_size"`
+		StoreText        *bool               `yaml:"store_text"`
+		Archives         *bool               `yaml:"archives"`
+		ArchiveMaxEntry  *int64              `yaml:"archive_max_entry_size"`
+		ArchiveMaxSize   *int64              `yaml:"archive_max_size"`
+		Passages         *string             `yaml:"passages"`
+		PassageWords     *int                `yaml:"passage_words"`
+		PDFFallback      []string            `yaml:"pdf_fallback"`
+		Commands         map[string][]string `yaml:"commands"`
+		CommandTimeout   *time.Duration      `yaml:"command_timeout"`
+		CommandMaxOutput *int64              `yaml:"command_max_output"`
+		Extensions       map[string]string   `yaml:"extensions"`
+	} `yaml:"readers"`
+
+	Tokenizer struct {
+		Language *string `yaml:"language"`
+	} `yaml:"tokenizer"`
+
+	Ranking struct {
+		MaxResults *int     `yaml:"max_results"`
+		MinScore   *float64 `yaml:"min_score"`
+		Fuzzy      *bool    `yaml:"fuzzy"`
+
+		CollapseDuplicates *bool `yaml:"collapse_duplicates"`
+
+		Synonyms      *string  `yaml:"synonyms"`
+		SynonymWeight *float64 `yaml:"synonym_weight"`
+	} `yaml:"ranking"`
+
+	Analyzer  *fileAnalyzer           `yaml:"analyzer"`
+	Analyzers map[string]fileAnalyzer `yaml:"analyzers"`
+
+	Sources []struct {
+		Collection string   `yaml:"collection"`
+		Path       string   `yaml:"path"`
+		Include    []string `yaml:"include"`
+		Exclude    []string `yaml:"exclude"`
+	} `yaml:"sources"`
+}
+
+type fileAnalyzer struct {
+	CharFilters []string `yaml:"char_filters"`
+	Tokenizer   string   `yaml:"tokenizer"`
+	Filters     []string `yaml:"filters"`
+	Language    string   `yaml:"language"`
+	StopWords   []string `yaml:"stop_words"`
+	MinLength   int      `yaml:"min_length"`
+	MaxLength   int      `yaml:"max_length"`
+}
+
+// configPath returns the config file path from -config, SCOUT_CONFIG or the
+// default, and whether it was set explicitly (a missing default file is fine).
+func configPath(args []string) (string, bool) {
+	for i, arg := range args {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "config" {
+			continue
+		}
+		if hasValue {
+			return value, true
+		}
+		if i+1 < len(args) {
+			return args[i+1], true
+		}
+	}
+	if path := os.Getenv("SCOUT_CONFIG"); path != "" {
+		return path, true
+	}
+	return defaultConfigPath, false
+}
+
+func loadFile(cfg *Config, path string, explicit bool) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !explicit {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read config file, err: %w", err)
+	}
+
+	var file fileConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %s, err: %w", path, err)
+	}
+
+	file.apply(cfg, filepath.Dir(path))
+	return nil
+}
+
+// apply copies the values set in the file into cfg, relative source
+// and synonyms paths are resolved against the config file directory.
+func (f *fileConfig) apply(cfg *Config, dir string) {
+	set(&cfg.DBPath, f.DB)
+	set(&cfg.SQLiteJournalMode, f.SQLite.JournalMode)
+	set(&cfg.SQLiteSynchronous, f.SQLite.Synchronous)
+	set(&cfg.SQLiteCacheSize, f.SQLite.CacheSize)
+	set(&cfg.SQLiteMmapSize, f.SQLite.MmapSize)
+	set(&cfg.SQLiteBusyTimeout, f.SQLite.BusyTimeout)
+	set(&cfg.Host, f.Server.Host)
+	set(&cfg.Port, f.Server.Port)
+	set(&cfg.MaxFileSize, f.Readers.MaxFileSize)
+	set(&cfg.StoreText, f.Readers.StoreText)
+	set(&cfg.Archives, f.Readers.Archives)
+	set(&cfg.ArchiveMaxEntrySize, f.Readers.ArchiveMaxEntry)
+	set(&cfg.ArchiveMaxSize, f.Readers.ArchiveMaxSize)
+	set(&cfg.Passages, f.Readers.Passages)
+	set(&cfg.PassageWords, f.Readers.PassageWords)
+	set(&cfg.CommandTimeout, f.Readers.CommandTimeout)
+	set(&cfg.CommandMaxOutput, f.Readers.CommandMaxOutput)
+	set(&cfg.Language, f.Tokenizer.Language)
+	set(&cfg.MaxResults, f.Ranking.MaxResults)
+	set(&cfg.MinScore, f.Ranking.MinScore)
+	set(&cfg.Fuzzy, f.Ranking.Fuzzy)
+	set(&cfg.CollapseDuplicates, f.Ranking.CollapseDuplicates)
+	set(&cfg.SynonymWeight, f.Ranking.SynonymWeight)
+	if f.Ranking.Synonyms != nil {
+		cfg.SynonymsFile = resolvePath(*f.Ranking.Synonyms, dir)
+	}
+
+	if f.Readers.Commands != nil {
+		cfg.Commands = f.Readers.Commands
+	}
+	if f.Readers.PDFFallback != nil {
+		cfg.PDFFallback = f.Readers.PDFFallback
+	}
+	if f.Readers.Extensions != nil {
+		cfg.Extensions = f.Readers.Extensions
+	}
+
+	if f.Analyzer != nil {
+		cfg.Analyzer = Analyzer(*f.Analyzer)
+	}
+	if f.Analyzers != nil {
+		cfg.Analyzers = make(map[string]Analyzer, len(f.Analyzers))
+		for collection, analyzer := range f.Analyzers {
+			cfg.Analyzers[collection] = Analyzer(analyzer)
+		}
+	}
+
+	for _, source := range f.Sources {
+		path := resolvePath(source.Path, dir)
+		collection := source.Collection
+		if collection == "" {
+			collection = cfg.Collection
+		}
+		cfg.Sources = append(cfg.Sources, Source{
+			Collection: collection,
+			Path:       path,
+			Include:    source.Include,
+			Exclude:    source.Exclude,
+		})
+	}
+}
+
+// resolvePath makes a relative path relative to dir.
+func resolvePath(path, dir string) string {
+	if path != "" && !filepath.IsAbs(path) {
+		return filepath.Join(dir, path)
+	}
+	return path
+}
+
+func set[T any](dst *T, value *T) {
+	if value != nil {
+		*dst = *value
+	}
+}
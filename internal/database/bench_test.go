@@ -0,0 +1,154 @@
+package database
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"testing"
+)
+
+// syntheticDocs builds numDocs documents of docLen terms each, drawn
+// from a vocabulary of vocabSize terms with a skewed (Zipf) distribution.
+func syntheticDocs(offset, numDocs, docLen, vocabSize int) []DocumentData {
+	rng := rand.New(rand.NewSource(int64(offset)))
+	zipf := rand.NewZipf(rng, 1.1, 1, uint64(vocabSize-1))
+
+	docs := make([]DocumentData, numDocs)
+	for i := range docs {
+		terms := make([]string, docLen)
+		for j := range terms {
+			terms[j] = fmt.Sprintf("term%d", zipf.Uint64())
+		}
+		docs[i] = DocumentData{Path: fmt.Sprintf("doc-%d.txt", offset+i), Terms: terms}
+	}
+	return docs
+}
+
+// BenchmarkAddDocuments compares journal/synchronous settings,
+// one iteration is one batch of 50 documents, as sent by the indexer.
+func BenchmarkAddDocuments(b *testing.B) {
+	const batchSize = 50
+
+	configs := []struct {
+		name        string
+		journalMode string
+		synchronous string
+	}{
+		{name: "delete-full", journalMode: "DELETE", synchronous: "FULL"},
+		{name: "wal-full", journalMode: "WAL", synchronous: "FULL"},
+		{name: "wal-normal", journalMode: "WAL", synchronous: "NORMAL"},
+	}
+
+	for _, cfg := range configs {
+		b.Run(cfg.name, func(b *testing.B) {
+			opts := DefaultSQLiteOptions()
+			opts.JournalMode = cfg.journalMode
+			opts.Synchronous = cfg.synchronous
+			db, err := OpenWithOptions(filepath.Join(b.TempDir(), "meta.db"), opts)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer db.Close()
+
+			batches := make([][]DocumentData, b.N)
+			for i := range batches {
+				batches[i] = syntheticDocs(i*batchSize, batchSize, 500, 20000)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := db.AddDocuments(batches[i]); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(b.N*batchSize)/b.Elapsed().Seconds(), "docs/s")
+		})
+	}
+}
+
+// BenchmarkIndexCorpus indexes whole synthetic corpora into a fresh database,
+// batch by batch, to show how indexing time grows with the vocabulary.
+func BenchmarkIndexCorpus(b *testing.B) {
+	const batchSize = 50
+
+	corpora := []struct {
+		name      string
+		numDocs   int
+		docLen    int
+		vocabSize int
+	}{
+		{name: "small", numDocs: 500, docLen: 300, vocabSize: 5000},
+		{name: "medium", numDocs: 2000, docLen: 500, vocabSize: 50000},
+		{name: "large-vocab", numDocs: 2000, docLen: 500, vocabSize: 500000},
+	}
+
+	for _, corpus := range corpora {
+		b.Run(corpus.name, func(b *testing.B) {
+			batches := make([][]DocumentData, 0, corpus.numDocs/batchSize)
+			for offset := 0; offset < corpus.numDocs; offset += batchSize {
+				batches = append(batches, syntheticDocs(offset, batchSize, corpus.docLen, corpus.vocabSize))
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				db, err := NewDatabase(filepath.Join(b.TempDir(), "meta.db"))
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.StartTimer()
+
+				for _, batch := range batches {
+					if err := db.AddDocuments(batch); err != nil {
+						b.Fatal(err)
+					}
+				}
+
+				b.StopTimer()
+				db.Close()
+				b.StartTimer()
+			}
+			b.ReportMetric(float64(b.N*corpus.numDocs)/b.Elapsed().Seconds(), "docs/s")
+		})
+	}
+}
+
+// BenchmarkTermIDs measures reading the IDs of a batch's terms, which
+// AddDocuments does for every batch instead of caching them (see upsertTerms),
+// in databases holding vocabularies of growing size. Compare with
+// BenchmarkAddDocuments, which includes it.
+func BenchmarkTermIDs(b *testing.B) {
+	const batchSize = 50
+
+	for _, vocabSize := range []int{5000, 50000, 500000} {
+		b.Run(fmt.Sprintf("vocab-%d", vocabSize), func(b *testing.B) {
+			db, err := NewDatabase(filepath.Join(b.TempDir(), "meta.db"))
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer db.Close()
+			for offset := 0; offset < 2000; offset += batchSize {
+				if err := db.AddDocuments(syntheticDocs(offset, batchSize, 500, vocabSize)); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			_, _, uniqueTerms, err := db.processDocuments(syntheticDocs(2000, batchSize, 500, vocabSize))
+			if err != nil {
+				b.Fatal(err)
+			}
+			texts := make([]string, 0, len(uniqueTerms))
+			for term := range uniqueTerms {
+				texts = append(texts, term)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := termIDs(db.db, texts); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(len(texts)), "terms/batch")
+		})
+	}
+}
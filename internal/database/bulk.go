@@ -0,0 +1,118 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/gfxv/scout/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxBindVars is SQLite's default SQLITE_MAX_VARIABLE_NUMBER (since 3.32),
+// PostgreSQL allows 65535, so the lower one is safe for both.
+const maxBindVars = 32766
+
+// chunkSize returns how many rows with numColumns bound values each
+// fit into a single statement.
+func chunkSize(numColumns int) int {
+	return maxBindVars / numColumns
+}
+
+// modelChunkSize returns how many rows of the model fit into a single
+// INSERT, counting every column of its schema.
+func modelChunkSize(tx *gorm.DB, model any) (int, error) {
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(model); err != nil {
+		return 0, fmt.Errorf("failed to parse schema of %T, err: %w", model, err)
+	}
+	return chunkSize(len(stmt.Schema.DBNames)), nil
+}
+
+// chunks splits items into consecutive slices of at most size elements.
+func chunks[T any](items []T, size int) [][]T {
+	result := make([][]T, 0, len(items)/size+1)
+	for len(items) > size {
+		result = append(result, items[:size])
+		items = items[size:]
+	}
+	if len(items) > 0 {
+		result = append(result, items)
+	}
+	return result
+}
+
+// termRow is a models.Term without the ID. RETURNING order isn't guaranteed
+// for upserts, so IDs are looked up separately instead of being returned.
+type termRow struct {
+	Text     string
+	DocCount uint
+	Surface  string
+}
+
+func (termRow) TableName() string { return "terms" }
+
+// upsertTerms inserts new terms and bumps doc_count of existing ones in
+// batched INSERT ... ON CONFLICT statements, then resolves the ID of every
+// term in uniqueTerms. Existing terms keep their surface form if they have one.
+//
+// IDs are deliberately not cached between batches but read again inside the
+// transaction: removing or re-indexing documents deletes terms no document
+// has anymore, ReplaceTerms recreates all of them, and another process
+// sharing a PostgreSQL database may do either at any time, so a cached ID
+// may point to a deleted or different term. The upsert has locked the rows,
+// so the IDs read can't change until the commit. See BenchmarkTermIDs for
+// the cost of reading them.
+func (d *Database) upsertTerms(tx *gorm.DB, uniqueTerms map[string]uint, surfaces map[string]string) (map[string]int, error) {
+	rows := make([]termRow, 0, len(uniqueTerms))
+	for term, docCount := range uniqueTerms {
+		rows = append(rows, termRow{Text: term, DocCount: docCount, Surface: surfaces[term]})
+	}
+
+	upsert := clause.OnConflict{
+		Columns: []clause.Column{{Name: "text"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"doc_count": gorm.Expr("terms.doc_count + excluded.doc_count"),
+			"surface":   gorm.Expr("CASE WHEN terms.surface = '' THEN excluded.surface ELSE terms.surface END"),
+		}),
+	}
+	for _, chunk := range chunks(rows, chunkSize(3)) {
+		if err := tx.Clauses(upsert).Create(&chunk).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	texts := make([]string, 0, len(uniqueTerms))
+	for term := range uniqueTerms {
+		texts = append(texts, term)
+	}
+	return termIDs(tx, texts)
+}
+
+// termIDs returns the IDs of the stored terms with the given texts.
+func termIDs(tx *gorm.DB, texts []string) (map[string]int, error) {
+	termIDs := make(map[string]int, len(texts))
+	for _, chunk := range chunks(texts, chunkSize(1)) {
+		var terms []models.Term
+		if err := tx.Select("id", "text").Where("text IN ?", chunk).Find(&terms).Error; err != nil {
+			return nil, err
+		}
+		for _, term := range terms {
+			termIDs[term.Text] = term.ID
+		}
+	}
+	return termIDs, nil
+}
+
+// surfaceForms merges the surface forms of the documents, keeping the best
+// word of a term, see models.BetterSurfaceForm.
+func surfaceForms(docs []DocumentData) map[string]string {
+	surfaces := make(map[string]string)
+	for _, doc := range docs {
+		for term, word := range doc.Surfaces {
+			if current, ok := surfaces[term]; !ok || models.BetterSurfaceForm(term, word, current) {
+				surfaces[term] = word
+			}
+		}
+	}
+	return surfaces
+}
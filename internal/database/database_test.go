@@ -0,0 +1,701 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gfxv/scout/internal/models"
+	"gorm.io/gorm"
+)
+
+// testDatabases returns a fresh SQLite database and, if SCOUT_TEST_POSTGRES_DSN
+// is set, an emptied PostgreSQL database, so every test runs against both backends.
+func testDatabases(t *testing.T) map[string]*Database {
+	t.Helper()
+
+	dbs := make(map[string]*Database)
+
+	sqliteDB, err := NewDatabase(filepath.Join(t.TempDir(), "meta.db"))
+	if err != nil {
+		t.Fatalf("failed to open sqlite database: %v", err)
+	}
+	dbs["sqlite"] = sqliteDB
+
+	if dsn := os.Getenv("SCOUT_TEST_POSTGRES_DSN"); dsn != "" {
+		pgDB, err := Open(dsn)
+		if err != nil {
+			t.Fatalf("failed to open postgres database: %v", err)
+		}
+		for _, table := range []any{&models.DocumentTerm{}, &models.Document{}, &models.Term{}, &models.DocumentText{}, &models.Collection{}, &models.CollectionRoot{}} {
+			if err := pgDB.db.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(table).Error; err != nil {
+				t.Fatalf("failed to clean postgres table: %v", err)
+			}
+		}
+		dbs["postgres"] = pgDB
+	}
+
+	for _, db := range dbs {
+		t.Cleanup(func() { db.Close() })
+	}
+	return dbs
+}
+
+func TestIsPostgresDSN(t *testing.T) {
+	tests := []struct {
+		dsn      string
+		expected bool
+	}{
+		{dsn: "meta.db", expected: false},
+		{dsn: "/app/db/meta.db", expected: false},
+		{dsn: "postgres://scout@localhost/scout", expected: true},
+		{dsn: "postgresql://scout@localhost/scout", expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dsn, func(t *testing.T) {
+			if got := IsPostgresDSN(tt.dsn); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestDatabase_AddAndLoad(t *testing.T) {
+	for name, db := range testDatabases(t) {
+		t.Run(name, func(t *testing.T) {
+			docs := []DocumentData{
+				{Path: "a.txt", Terms: []string{"alpha", "beta", "beta"}},
+				{Path: "b.txt", Terms: []string{"beta", "gamma"}},
+			}
+			if err := db.AddDocuments(docs); err != nil {
+				t.Fatalf("AddDocuments failed: %v", err)
+			}
+			// a second batch must update doc counts of already known terms
+			if err := db.AddDocuments([]DocumentData{{Path: "c.txt", Terms: []string{"alpha"}}}); err != nil {
+				t.Fatalf("AddDocuments failed: %v", err)
+			}
+
+			docIndex, docFreq, err := db.LoadIndexData()
+			if err != nil {
+				t.Fatalf("LoadIndexData failed: %v", err)
+			}
+			if len(docIndex) != 3 {
+				t.Fatalf("expected 3 documents, got %d", len(docIndex))
+			}
+			if got := docIndex["a.txt"].Terms["beta"]; got != 2 {
+				t.Errorf("expected count of \"beta\" in a.txt = 2, got %d", got)
+			}
+			if got := docIndex["a.txt"].TotalTerms; got != 3 {
+				t.Errorf("expected 3 total terms in a.txt, got %d", got)
+			}
+			expectedFreq := models.TermFreq{"alpha": 2, "beta": 2, "gamma": 1}
+			for term, want := range expectedFreq {
+				if got := docFreq[term]; got != want {
+					t.Errorf("expected doc frequency of %q = %d, got %d", term, want, got)
+				}
+			}
+
+			total, err := db.GetTotalDocuments()
+			if err != nil {
+				t.Fatalf("GetTotalDocuments failed: %v", err)
+			}
+			if total != 3 {
+				t.Errorf("expected 3 total documents, got %d", total)
+			}
+		})
+	}
+}
+
+func TestDatabase_RemoveDocument(t *testing.T) {
+	for name, db := range testDatabases(t) {
+		t.Run(name, func(t *testing.T) {
+			docs := []DocumentData{
+				{Path: "a.txt", Terms: []string{"alpha", "beta"}},
+				{Path: "b.txt", Terms: []string{"beta"}},
+			}
+			if err := db.AddDocuments(docs); err != nil {
+				t.Fatalf("AddDocuments failed: %v", err)
+			}
+			if err := db.RemoveDocument("a.txt"); err != nil {
+				t.Fatalf("RemoveDocument failed: %v", err)
+			}
+			if err := db.RemoveDocument("a.txt"); err == nil {
+				t.Error("expected error when removing a missing document")
+			}
+
+			docIndex, docFreq, err := db.LoadIndexData()
+			if err != nil {
+				t.Fatalf("LoadIndexData failed: %v", err)
+			}
+			if _, ok := docIndex["a.txt"]; ok {
+				t.Error("expected a.txt to be removed")
+			}
+			if _, ok := docFreq["alpha"]; ok {
+				t.Error("expected term \"alpha\" to be removed")
+			}
+			if got := docFreq["beta"]; got != 1 {
+				t.Errorf("expected doc frequency of \"beta\" = 1, got %d", got)
+			}
+		})
+	}
+}
+
+func TestDatabase_ReadDuringWrite(t *testing.T) {
+	opts := DefaultSQLiteOptions()
+	// a blocked reader would fail right away instead of waiting
+	opts.BusyTimeout = 0
+	db, err := OpenWithOptions(filepath.Join(t.TempDir(), "meta.db"), opts)
+	if err != nil {
+		t.Fatalf("OpenWithOptions failed: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.AddDocuments([]DocumentData{{Path: "a.txt", Terms: []string{"alpha"}}}); err != nil {
+		t.Fatalf("AddDocuments failed: %v", err)
+	}
+
+	tx := db.db.Begin()
+	defer tx.Rollback()
+	if err := tx.Create(&models.Document{Path: "b.txt"}).Error; err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+
+	docIndex, _, err := db.LoadIndexData()
+	if err != nil {
+		t.Fatalf("LoadIndexData during write failed: %v", err)
+	}
+	if len(docIndex) != 1 {
+		t.Errorf("expected only the committed document, got %d documents", len(docIndex))
+	}
+}
+
+func TestSQLiteOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*SQLiteOptions)
+		wantErr bool
+	}{
+		{name: "Defaults", modify: func(o *SQLiteOptions) {}},
+		{name: "Lowercase modes", modify: func(o *SQLiteOptions) { o.JournalMode, o.Synchronous = "delete", "full" }},
+		{name: "Unknown journal mode", modify: func(o *SQLiteOptions) { o.JournalMode = "WAL; DROP TABLE terms" }, wantErr: true},
+		{name: "Unknown synchronous mode", modify: func(o *SQLiteOptions) { o.Synchronous = "SOMETIMES" }, wantErr: true},
+		{name: "Negative mmap size", modify: func(o *SQLiteOptions) { o.MmapSize = -1 }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultSQLiteOptions()
+			tt.modify(&opts)
+			if err := opts.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("expected error = %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestDatabase_AddAfterRemove(t *testing.T) {
+	for name, db := range testDatabases(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := db.AddDocuments([]DocumentData{{Path: "a.txt", Terms: []string{"alpha", "beta"}}}); err != nil {
+				t.Fatalf("AddDocuments failed: %v", err)
+			}
+			// deletes "alpha", the cached term ID must not be reused
+			if err := db.RemoveDocument("a.txt"); err != nil {
+				t.Fatalf("RemoveDocument failed: %v", err)
+			}
+			if err := db.AddDocuments([]DocumentData{{Path: "b.txt", Terms: []string{"alpha", "alpha"}}}); err != nil {
+				t.Fatalf("AddDocuments failed: %v", err)
+			}
+
+			docIndex, docFreq, err := db.LoadIndexData()
+			if err != nil {
+				t.Fatalf("LoadIndexData failed: %v", err)
+			}
+			if got := docIndex["b.txt"].Terms["alpha"]; got != 2 {
+				t.Errorf("expected count of \"alpha\" in b.txt = 2, got %d", got)
+			}
+			if got := docFreq["alpha"]; got != 1 {
+				t.Errorf("expected doc frequency of \"alpha\" = 1, got %d", got)
+			}
+		})
+	}
+}
+
+func TestDatabase_SharedWriters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "meta.db")
+	first, err := NewDatabase(path)
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	defer first.Close()
+	second, err := NewDatabase(path)
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	defer second.Close()
+
+	if err := first.AddDocuments([]DocumentData{{Path: "a.txt", Terms: []string{"alpha"}}}); err != nil {
+		t.Fatalf("AddDocuments failed: %v", err)
+	}
+	// another writer deletes "alpha" and creates it again with a new ID
+	if err := second.RemoveDocument("a.txt"); err != nil {
+		t.Fatalf("RemoveDocument failed: %v", err)
+	}
+	if err := second.AddDocuments([]DocumentData{{Path: "b.txt", Terms: []string{"beta"}}}); err != nil {
+		t.Fatalf("AddDocuments failed: %v", err)
+	}
+	if err := second.AddDocuments([]DocumentData{{Path: "c.txt", Terms: []string{"alpha"}}}); err != nil {
+		t.Fatalf("AddDocuments failed: %v", err)
+	}
+	if err := first.AddDocuments([]DocumentData{{Path: "d.txt", Terms: []string{"alpha"}}}); err != nil {
+		t.Fatalf("AddDocuments failed: %v", err)
+	}
+
+	docIndex, docFreq, err := first.LoadIndexData()
+	if err != nil {
+		t.Fatalf("LoadIndexData failed: %v", err)
+	}
+	if got := docIndex["d.txt"].Terms["alpha"]; got != 1 {
+		t.Errorf("expected \"alpha\" in d.txt, got count %d", got)
+	}
+	if got := docFreq["alpha"]; got != 2 {
+		t.Errorf("expected doc frequency of \"alpha\" = 2, got %d", got)
+	}
+}
+
+func TestDatabase_AddLargeBatch(t *testing.T) {
+	// more unique terms than fit into a single statement
+	numTerms := chunkSize(1) + 10
+	terms := make([]string, numTerms)
+	for i := range terms {
+		terms[i] = fmt.Sprintf("term%d", i)
+	}
+
+	for name, db := range testDatabases(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := db.AddDocuments([]DocumentData{{Path: "big.txt", Terms: terms}}); err != nil {
+				t.Fatalf("AddDocuments failed: %v", err)
+			}
+			docIndex, docFreq, err := db.LoadIndexData()
+			if err != nil {
+				t.Fatalf("LoadIndexData failed: %v", err)
+			}
+			if got := len(docIndex["big.txt"].Terms); got != numTerms {
+				t.Errorf("expected %d terms in big.txt, got %d", numTerms, got)
+			}
+			if got := len(docFreq); got != numTerms {
+				t.Errorf("expected %d terms in doc frequency, got %d", numTerms, got)
+			}
+		})
+	}
+}
+
+func TestChunks(t *testing.T) {
+	tests := []struct {
+		name     string
+		items    []int
+		size     int
+		expected [][]int
+	}{
+		{name: "Empty", items: []int{}, size: 2, expected: [][]int{}},
+		{name: "Exact", items: []int{1, 2, 3, 4}, size: 2, expected: [][]int{{1, 2}, {3, 4}}},
+		{name: "Remainder", items: []int{1, 2, 3}, size: 2, expected: [][]int{{1, 2}, {3}}},
+		{name: "Single chunk", items: []int{1, 2}, size: 5, expected: [][]int{{1, 2}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := chunks(tt.items, tt.size)
+			if fmt.Sprint(result) != fmt.Sprint(tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestDatabase_Collections(t *testing.T) {
+	for name, db := range testDatabases(t) {
+		t.Run(name, func(t *testing.T) {
+			docs := []DocumentData{
+				{Collection: "specs", Path: "specs/a.md", Terms: []string{"alpha"}},
+				{Collection: "specs", Path: "specs/b.md", Terms: []string{"beta"}},
+				{Collection: "runbooks", Path: "runbooks/c.md", Terms: []string{"alpha"}},
+			}
+			if err := db.AddDocuments(docs); err != nil {
+				t.Fatalf("AddDocuments failed: %v", err)
+			}
+			if err := db.SaveCollection("specs", "specs", "a1"); err != nil {
+				t.Fatalf("SaveCollection failed: %v", err)
+			}
+			if err := db.SaveCollection("runbooks", "runbooks", "a1"); err != nil {
+				t.Fatalf("SaveCollection failed: %v", err)
+			}
+			// saving again updates the existing collection
+			if err := db.SaveCollection("specs", "new/specs", "a2"); err != nil {
+				t.Fatalf("SaveCollection failed: %v", err)
+			}
+
+			collections, err := db.GetCollections()
+			if err != nil {
+				t.Fatalf("GetCollections failed: %v", err)
+			}
+			if len(collections) != 2 {
+				t.Fatalf("expected 2 collections, got %+v", collections)
+			}
+			runbooks, specs := collections[0], collections[1]
+			if runbooks.Name != "runbooks" || runbooks.Documents != 1 {
+				t.Errorf("unexpected runbooks stats: %+v", runbooks)
+			}
+			if specs.Name != "specs" || specs.Documents != 2 || specs.Root != "new/specs" {
+				t.Errorf("unexpected specs stats: %+v", specs)
+			}
+			if roots := strings.Join(specs.Roots, ","); roots != "specs,new/specs" {
+				t.Errorf("expected both roots of specs, got %q", roots)
+			}
+			if specs.Analyzer != "a2" {
+				t.Errorf("expected analyzer %q, got %q", "a2", specs.Analyzer)
+			}
+			if specs.LastIndexedAt.IsZero() {
+				t.Error("expected last indexed time to be set")
+			}
+
+			docIndex, _, err := db.LoadIndexData()
+			if err != nil {
+				t.Fatalf("LoadIndexData failed: %v", err)
+			}
+			if got := docIndex["runbooks/c.md"].Collection; got != "runbooks" {
+				t.Errorf("expected collection \"runbooks\", got %q", got)
+			}
+		})
+	}
+}
+
+func TestDatabase_ReplaceTerms(t *testing.T) {
+	stores := map[string]IndexStore{"memory": NewMemoryStore()}
+	for name, db := range testDatabases(t) {
+		stores[name] = db
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			docs := []DocumentData{
+				{Path: "a.txt", Terms: []string{"running", "fast"}},
+				{Path: "b.txt", Terms: []string{"running"}},
+			}
+			if err := store.AddDocuments(docs); err != nil {
+				t.Fatalf("AddDocuments failed: %v", err)
+			}
+			if err := store.ReplaceTerms(docs[:1]); err == nil {
+				t.Error("expected error when not all documents are given")
+			}
+
+			replaced := []DocumentData{
+				{Path: "a.txt", Terms: []string{"run", "fast", "run"}},
+				{Path: "b.txt", Terms: []string{"run"}},
+			}
+			if err := store.ReplaceTerms(replaced); err != nil {
+				t.Fatalf("ReplaceTerms failed: %v", err)
+			}
+
+			docIndex, docFreq, err := store.LoadIndexData()
+			if err != nil {
+				t.Fatalf("LoadIndexData failed: %v", err)
+			}
+			if _, ok := docFreq["running"]; ok {
+				t.Error("expected term \"running\" to be removed")
+			}
+			if got := docFreq["run"]; got != 2 {
+				t.Errorf("expected doc frequency of \"run\" = 2, got %d", got)
+			}
+			if got := docIndex["a.txt"].Terms["run"]; got != 2 {
+				t.Errorf("expected count of \"run\" in a.txt = 2, got %d", got)
+			}
+			if got := docIndex["a.txt"].TotalTerms; got != 3 {
+				t.Errorf("expected 3 total terms in a.txt, got %d", got)
+			}
+
+			// new documents still get the right term IDs
+			if err := store.AddDocuments([]DocumentData{{Path: "c.txt", Terms: []string{"run"}}}); err != nil {
+				t.Fatalf("AddDocuments failed: %v", err)
+			}
+			_, docFreq, err = store.LoadIndexData()
+			if err != nil {
+				t.Fatalf("LoadIndexData failed: %v", err)
+			}
+			if got := docFreq["run"]; got != 3 {
+				t.Errorf("expected doc frequency of \"run\" = 3, got %d", got)
+			}
+		})
+	}
+}
+
+func TestDatabase_Texts(t *testing.T) {
+	stores := map[string]IndexStore{"memory": NewMemoryStore()}
+	for name, db := range testDatabases(t) {
+		stores[name] = db
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			docs := []DocumentData{
+				{Path: "a.txt", Terms: []string{"alpha"}, ContentHash: "h1", Text: "Alpha text", SimHash: 1<<63 | 5},
+				// same content as a.txt, the text is stored once
+				{Path: "copy.txt", Terms: []string{"alpha"}, ContentHash: "h1", Text: "Alpha text"},
+				{Path: "b.txt", Terms: []string{"beta"}},
+				{Path: "scan.pdf", ContentHash: "h2", Text: "\f\f", NoText: true, FailedPages: 1},
+			}
+			if err := store.AddDocuments(docs); err != nil {
+				t.Fatalf("AddDocuments failed: %v", err)
+			}
+
+			// the problems extracting a text are kept with it
+			stored, err := store.GetStoredText("h2")
+			if err != nil {
+				t.Fatalf("GetStoredText failed: %v", err)
+			}
+			if stored.Text != "\f\f" || !stored.NoText || stored.FailedPages != 1 {
+				t.Errorf("expected the text without text and 1 failed page, got %+v", stored)
+			}
+
+			text, err := store.GetText("h1")
+			if err != nil {
+				t.Fatalf("GetText failed: %v", err)
+			}
+			if text != "Alpha text" {
+				t.Errorf("expected %q, got %q", "Alpha text", text)
+			}
+			docIndex, _, err := store.LoadIndexData()
+			if err != nil {
+				t.Fatalf("LoadIndexData failed: %v", err)
+			}
+			if got := docIndex["copy.txt"].ContentHash; got != "h1" {
+				t.Errorf("expected content hash %q, got %q", "h1", got)
+			}
+			// stored as a signed integer
+			if got := docIndex["a.txt"].SimHash; got != 1<<63|5 {
+				t.Errorf("expected SimHash %d, got %d", uint64(1<<63|5), got)
+			}
+
+			// the text is kept until the last document with the content is removed
+			if err := store.RemoveDocument("a.txt"); err != nil {
+				t.Fatalf("RemoveDocument failed: %v", err)
+			}
+			if _, err := store.GetText("h1"); err != nil {
+				t.Errorf("expected text to be kept, got %v", err)
+			}
+			if err := store.RemoveDocument("copy.txt"); err != nil {
+				t.Fatalf("RemoveDocument failed: %v", err)
+			}
+			if _, err := store.GetText("h1"); !errors.Is(err, ErrTextNotFound) {
+				t.Errorf("expected ErrTextNotFound, got %v", err)
+			}
+		})
+	}
+}
+
+func TestDatabase_Passages(t *testing.T) {
+	stores := map[string]IndexStore{"memory": NewMemoryStore()}
+	for name, db := range testDatabases(t) {
+		stores[name] = db
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			docs := []DocumentData{
+				{Path: "docs/book.pdf#page-1", Parent: "docs/book.pdf", Passage: "page 1", Page: 1, Terms: []string{"alpha"}, ContentHash: "h1", Text: "alpha\fbeta"},
+				{Path: "docs/book.pdf#page-2", Parent: "docs/book.pdf", Passage: "page 2", Page: 2, Terms: []string{"beta"}, ContentHash: "h1"},
+				{Path: "docs/notes.txt", Terms: []string{"alpha"}},
+			}
+			if err := store.AddDocuments(docs); err != nil {
+				t.Fatalf("AddDocuments failed: %v", err)
+			}
+			if err := store.SaveCollection("", "docs", ""); err != nil {
+				t.Fatalf("SaveCollection failed: %v", err)
+			}
+
+			docIndex, _, err := store.LoadIndexData()
+			if err != nil {
+				t.Fatalf("LoadIndexData failed: %v", err)
+			}
+			page := docIndex["docs/book.pdf#page-2"]
+			if page.Parent != "docs/book.pdf" || page.Passage != "page 2" || page.Page != 2 {
+				t.Errorf("expected passage page 2 of docs/book.pdf, got %q (page %d) of %q", page.Passage, page.Page, page.Parent)
+			}
+			// facets are those of the file
+			if page.Extension != ".pdf" || page.Dir != "docs" {
+				t.Errorf("expected extension .pdf in docs, got %q in %q", page.Extension, page.Dir)
+			}
+
+			collections, err := store.GetCollections()
+			if err != nil {
+				t.Fatalf("GetCollections failed: %v", err)
+			}
+			if len(collections) != 1 || collections[0].Documents != 2 {
+				t.Errorf("expected 2 files in one collection, got %+v", collections)
+			}
+
+			// removing the file removes all its passages
+			if err := store.RemoveDocument("docs/book.pdf"); err != nil {
+				t.Fatalf("RemoveDocument failed: %v", err)
+			}
+			docIndex, docFreq, err := store.LoadIndexData()
+			if err != nil {
+				t.Fatalf("LoadIndexData failed: %v", err)
+			}
+			if len(docIndex) != 1 {
+				t.Errorf("expected 1 document left, got %d", len(docIndex))
+			}
+			if docFreq["beta"] != 0 || docFreq["alpha"] != 1 {
+				t.Errorf("expected alpha in 1 and beta in no documents, got %v", docFreq)
+			}
+			if _, err := store.GetText("h1"); !errors.Is(err, ErrTextNotFound) {
+				t.Errorf("expected ErrTextNotFound, got %v", err)
+			}
+		})
+	}
+}
+
+func TestDatabase_AddManyPassages(t *testing.T) {
+	// more rows than fit into a single statement with every column of documents
+	numPassages := chunkSize(13) + 100
+	docs := make([]DocumentData, numPassages)
+	for i := range docs {
+		docs[i] = DocumentData{
+			Path:    fmt.Sprintf("book.pdf#page-%d", i+1),
+			Parent:  "book.pdf",
+			Passage: fmt.Sprintf("page %d", i+1),
+			Page:    i + 1,
+			Terms:   []string{"alpha"},
+		}
+	}
+
+	for name, db := range testDatabases(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := db.AddDocuments(docs); err != nil {
+				t.Fatalf("AddDocuments failed: %v", err)
+			}
+			docIndex, docFreq, err := db.LoadIndexData()
+			if err != nil {
+				t.Fatalf("LoadIndexData failed: %v", err)
+			}
+			if len(docIndex) != numPassages {
+				t.Errorf("expected %d passages, got %d", numPassages, len(docIndex))
+			}
+			if got := docFreq["alpha"]; got != uint(numPassages) {
+				t.Errorf("expected doc frequency of \"alpha\" = %d, got %d", numPassages, got)
+			}
+		})
+	}
+}
+
+func TestDatabase_SurfaceForms(t *testing.T) {
+	stores := map[string]IndexStore{"memory": NewMemoryStore()}
+	for name, db := range testDatabases(t) {
+		stores[name] = db
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			docs := []DocumentData{
+				{Path: "a.md", Terms: []string{"kubernet", "run"}, Surfaces: map[string]string{"kubernet": "kubernetes", "run": "running"}},
+				{Path: "b.md", Terms: []string{"run"}, Surfaces: map[string]string{"run": "runs"}},
+			}
+			if err := store.AddDocuments(docs); err != nil {
+				t.Fatalf("AddDocuments failed: %v", err)
+			}
+			// existing terms keep their surface form
+			if err := store.AddDocuments([]DocumentData{{Path: "c.md", Terms: []string{"run"}, Surfaces: map[string]string{"run": "run"}}}); err != nil {
+				t.Fatalf("AddDocuments failed: %v", err)
+			}
+
+			surfaces, err := store.GetSurfaceForms()
+			if err != nil {
+				t.Fatalf("GetSurfaceForms failed: %v", err)
+			}
+			if surfaces["kubernet"] != "kubernetes" || surfaces["run"] != "runs" {
+				t.Errorf("expected kubernetes and runs, got %v", surfaces)
+			}
+
+			if err := store.RemoveDocument("a.md"); err != nil {
+				t.Fatalf("RemoveDocument failed: %v", err)
+			}
+			if surfaces, _ := store.GetSurfaceForms(); len(surfaces) != 1 {
+				t.Errorf("expected the surface form of removed terms to be gone, got %v", surfaces)
+			}
+		})
+	}
+}
+
+func TestDatabase_AddAgain(t *testing.T) {
+	stores := map[string]IndexStore{"memory": NewMemoryStore()}
+	for name, db := range testDatabases(t) {
+		stores[name] = db
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			docs := []DocumentData{
+				{Path: "a.txt", Terms: []string{"alpha", "old"}, ContentHash: "h1", Text: "alpha old"},
+				{Path: "b.txt#p1", Parent: "b.txt", Terms: []string{"beta"}, ContentHash: "h2", Text: "beta gamma"},
+				{Path: "b.txt#p2", Parent: "b.txt", Terms: []string{"gamma"}, ContentHash: "h2"},
+				{Path: "same.txt", Terms: []string{"alpha"}, ContentHash: "h3", Text: "alpha"},
+			}
+			if err := store.AddDocuments(docs); err != nil {
+				t.Fatalf("AddDocuments failed: %v", err)
+			}
+
+			// a.txt changed, b.txt is no longer split, same.txt is unchanged
+			// and its text already stored, new.txt is new
+			modTime := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+			again := []DocumentData{
+				{Path: "a.txt", Terms: []string{"alpha", "new"}, ContentHash: "h4", Text: "alpha new", ModTime: modTime},
+				{Path: "b.txt", Terms: []string{"beta", "gamma"}, ContentHash: "h2"},
+				{Path: "same.txt", Terms: []string{"alpha"}, ContentHash: "h3", ModTime: modTime},
+				{Path: "new.txt", Terms: []string{"new"}},
+			}
+			if err := store.AddDocuments(again); err != nil {
+				t.Fatalf("AddDocuments of indexed files failed: %v", err)
+			}
+
+			docIndex, docFreq, err := store.LoadIndexData()
+			if err != nil {
+				t.Fatalf("LoadIndexData failed: %v", err)
+			}
+			paths := make([]string, 0, len(docIndex))
+			for path := range docIndex {
+				paths = append(paths, path)
+			}
+			sort.Strings(paths)
+			if got := strings.Join(paths, ","); got != "a.txt,b.txt,new.txt,same.txt" {
+				t.Errorf("expected the documents of the second batch, got %s", got)
+			}
+			expected := map[string]uint{"alpha": 2, "beta": 1, "gamma": 1, "new": 2}
+			if len(docFreq) != len(expected) {
+				t.Errorf("expected doc frequencies %v, got %v", expected, docFreq)
+			}
+			for term, count := range expected {
+				if docFreq[term] != count {
+					t.Errorf("expected doc frequency of %q = %d, got %d", term, count, docFreq[term])
+				}
+			}
+			if got := docIndex["same.txt"].ModTime; !got.Equal(modTime) {
+				t.Errorf("expected modification time %v, got %v", modTime, got)
+			}
+
+			for hash, want := range map[string]string{"h2": "beta gamma", "h3": "alpha", "h4": "alpha new"} {
+				if text, err := store.GetText(hash); err != nil || text != want {
+					t.Errorf("expected text %q for %s, got %q, %v", want, hash, text, err)
+				}
+			}
+			if _, err := store.GetText("h1"); !errors.Is(err, ErrTextNotFound) {
+				t.Errorf("expected the replaced text to be removed, got %v", err)
+			}
+		})
+	}
+}
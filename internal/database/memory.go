@@ -0,0 +1,278 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gfxv/scout/internal/models"
+)
+
+// MemoryStore keeps the whole index in memory. Useful for tests
+// and for ephemeral indexes that don't need to survive a restart.
+type MemoryStore struct {
+	mu           *sync.Mutex
+	docIndex     models.DocIndex
+	docFrequency models.TermFreq
+	collections  map[string]models.CollectionStats
+	surfaces     map[string]string
+	// texts are compressed like in the database, by content hash
+	texts  map[string]models.DocumentText
+	lastID int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		mu:           &sync.Mutex{},
+		docIndex:     make(models.DocIndex),
+		docFrequency: make(models.TermFreq),
+		collections:  make(map[string]models.CollectionStats),
+		surfaces:     make(map[string]string),
+		texts:        make(map[string]models.DocumentText),
+	}
+}
+
+func (m *MemoryStore) AddDocuments(docs []DocumentData) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// files indexed again replace their documents
+	replaced := make([]models.DocInfo, 0)
+	for _, doc := range docs {
+		replaced = append(replaced, m.remove(doc.filePath())...)
+	}
+
+	texts, err := storedTexts(docs)
+	if err != nil {
+		return err
+	}
+	for _, text := range texts {
+		m.texts[text.Hash] = text
+	}
+	// existing terms keep their surface form, like in the database
+	for term, word := range surfaceForms(docs) {
+		if _, ok := m.surfaces[term]; !ok {
+			m.surfaces[term] = word
+		}
+	}
+
+	for _, doc := range docs {
+		tf := make(models.TermFreq)
+		for _, term := range doc.Terms {
+			tf[term]++
+		}
+		for term := range tf {
+			m.docFrequency[term]++
+		}
+		m.lastID++
+		m.docIndex[doc.Path] = models.DocInfo{
+			ID:          m.lastID,
+			Collection:  collectionOrDefault(doc.Collection),
+			ContentHash: doc.ContentHash,
+			SimHash:     doc.SimHash,
+			Parent:      doc.Parent,
+			Passage:     doc.Passage,
+			Page:        doc.Page,
+			NoText:      doc.NoText,
+			FailedPages: doc.FailedPages,
+			Extension:   extensionOf(doc.filePath()),
+			Dir:         dirOf(doc.filePath()),
+			ModTime:     doc.ModTime,
+			Terms:       tf,
+			TotalTerms:  uint(len(doc.Terms)),
+		}
+	}
+	m.removeTexts(replaced)
+	return nil
+}
+
+// RemoveDocument removes the document with the given path, or all
+// passages of the file with the given path.
+func (m *MemoryStore) RemoveDocument(path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	removed := m.remove(path)
+	if len(removed) == 0 {
+		return fmt.Errorf("document %s not found", path)
+	}
+	m.removeTexts(removed)
+	return nil
+}
+
+// remove removes the document with the given path, or all passages of the
+// file with the given path, and returns them. Their texts are left.
+func (m *MemoryStore) remove(path string) []models.DocInfo {
+	removed := make([]models.DocInfo, 0)
+	for docPath, docInfo := range m.docIndex {
+		if docPath != path && docInfo.Parent != path {
+			continue
+		}
+		for term := range docInfo.Terms {
+			if m.docFrequency[term] > 0 {
+				m.docFrequency[term]--
+			}
+			if m.docFrequency[term] == 0 {
+				delete(m.docFrequency, term)
+				delete(m.surfaces, term)
+			}
+		}
+		delete(m.docIndex, docPath)
+		removed = append(removed, docInfo)
+	}
+	return removed
+}
+
+// removeTexts deletes the texts of the removed documents, unless
+// other documents still have the same content.
+func (m *MemoryStore) removeTexts(removed []models.DocInfo) {
+	for _, docInfo := range removed {
+		if docInfo.ContentHash != "" && !m.hasContent(docInfo.ContentHash) {
+			delete(m.texts, docInfo.ContentHash)
+		}
+	}
+}
+
+func (m *MemoryStore) hasContent(hash string) bool {
+	for _, docInfo := range m.docIndex {
+		if docInfo.ContentHash == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadIndexData returns copies of the stored maps, so the caller
+// is free to modify them.
+func (m *MemoryStore) LoadIndexData() (models.DocIndex, models.TermFreq, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	docIndex := make(models.DocIndex, len(m.docIndex))
+	for path, docInfo := range m.docIndex {
+		terms := make(models.TermFreq, len(docInfo.Terms))
+		for term, count := range docInfo.Terms {
+			terms[term] = count
+		}
+		docInfo.Terms = terms
+		docIndex[path] = docInfo
+	}
+
+	docFrequency := make(models.TermFreq, len(m.docFrequency))
+	for term, count := range m.docFrequency {
+		docFrequency[term] = count
+	}
+
+	return docIndex, docFrequency, nil
+}
+
+func (m *MemoryStore) GetTotalDocuments() (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return int64(len(m.docIndex)), nil
+}
+
+func (m *MemoryStore) ReplaceTerms(docs []DocumentData) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(docs) != len(m.docIndex) {
+		return fmt.Errorf("expected terms of all %d documents, got %d", len(m.docIndex), len(docs))
+	}
+	for _, doc := range docs {
+		if _, ok := m.docIndex[doc.Path]; !ok {
+			return fmt.Errorf("document %s not found", doc.Path)
+		}
+	}
+
+	m.docFrequency = make(models.TermFreq)
+	m.surfaces = surfaceForms(docs)
+	for _, doc := range docs {
+		tf := make(models.TermFreq)
+		for _, term := range doc.Terms {
+			tf[term]++
+		}
+		for term := range tf {
+			m.docFrequency[term]++
+		}
+		docInfo := m.docIndex[doc.Path]
+		docInfo.Terms = tf
+		docInfo.TotalTerms = uint(len(doc.Terms))
+		m.docIndex[doc.Path] = docInfo
+	}
+	return nil
+}
+
+func (m *MemoryStore) SaveCollection(name, root, analyzer string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name = collectionOrDefault(name)
+	roots := m.collections[name].Roots
+	if root != "" && !slices.Contains(roots, root) {
+		roots = append(slices.Clip(roots), root)
+	}
+	m.collections[name] = models.CollectionStats{
+		Name:          name,
+		Root:          root,
+		Roots:         roots,
+		LastIndexedAt: time.Now(),
+		Analyzer:      analyzer,
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetCollections() ([]models.CollectionStats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// passages are counted once per file
+	files := make(map[string]string)
+	for path, docInfo := range m.docIndex {
+		files[docInfo.FilePath(path)] = docInfo.Collection
+	}
+	counts := make(map[string]int64)
+	for _, collection := range files {
+		counts[collection]++
+	}
+
+	stats := make([]models.CollectionStats, 0, len(m.collections))
+	for name, collection := range m.collections {
+		collection.Documents = counts[name]
+		stats = append(stats, collection)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Name < stats[j].Name
+	})
+	return stats, nil
+}
+
+func (m *MemoryStore) GetSurfaceForms() (map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	surfaces := make(map[string]string, len(m.surfaces))
+	for term, word := range m.surfaces {
+		surfaces[term] = word
+	}
+	return surfaces, nil
+}
+
+func (m *MemoryStore) GetText(hash string) (string, error) {
+	text, err := m.GetStoredText(hash)
+	return text.Text, err
+}
+
+func (m *MemoryStore) GetStoredText(hash string) (StoredText, error) {
+	m.mu.Lock()
+	text, ok := m.texts[hash]
+	m.mu.Unlock()
+	if !ok {
+		return StoredText{}, fmt.Errorf("%w: %s", ErrTextNotFound, hash)
+	}
+	return storedText(text)
+}
+
+func (m *MemoryStore) Close() error {
+	return nil
+}
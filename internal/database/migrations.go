@@ -0,0 +1,401 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gfxv/scout/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSchemaTooNew   = errors.New("database schema is newer than this binary supports")
+	ErrSchemaOutdated = errors.New("database schema is outdated, run `scout migrate`")
+)
+
+type migration struct {
+	version int
+	name    string
+	up      func(tx *gorm.DB) error
+}
+
+// migrations must only ever be appended to. Each migration works on its own
+// snapshot of the tables it touches, so later changes in the models package
+// don't change what an old migration does.
+var migrations = []migration{
+	{version: 1, name: "initial schema", up: migrateInitialSchema},
+	{version: 2, name: "collections", up: migrateCollections},
+	{version: 3, name: "document facets", up: migrateDocumentFacets},
+	{version: 4, name: "document texts", up: migrateDocumentTexts},
+	{version: 5, name: "collection analyzers", up: migrateCollectionAnalyzers},
+	{version: 6, name: "document signatures", up: migrateDocumentSignatures},
+	{version: 7, name: "document passages", up: migrateDocumentPassages},
+	{version: 8, name: "document pages", up: migrateDocumentPages},
+	{version: 9, name: "document extraction problems", up: migrateDocumentExtraction},
+	{version: 10, name: "collection roots", up: migrateCollectionRoots},
+	{version: 11, name: "term surface forms", up: migrateTermSurfaces},
+	{version: 12, name: "text extraction problems", up: migrateTextExtraction},
+}
+
+// LatestSchemaVersion is the schema version this binary expects.
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// Migrate applies all pending migrations to the database described by dsn
+// and returns the schema versions before and after.
+func Migrate(dsn string) (int, int, error) {
+	return MigrateWithOptions(dsn, DefaultSQLiteOptions())
+}
+
+// MigrateWithOptions is like Migrate, sqliteOpts are ignored for PostgreSQL.
+func MigrateWithOptions(dsn string, sqliteOpts SQLiteOptions) (int, int, error) {
+	d, err := connect(dsn, sqliteOpts)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer d.Close()
+
+	from, err := d.SchemaVersion()
+	if err != nil {
+		return 0, 0, err
+	}
+	if from > LatestSchemaVersion() {
+		return from, from, fmt.Errorf("%w (database: %d, supported: %d)", ErrSchemaTooNew, from, LatestSchemaVersion())
+	}
+	if err := d.migrate(); err != nil {
+		return from, 0, err
+	}
+	to, err := d.SchemaVersion()
+	return from, to, err
+}
+
+// SchemaVersion returns the version of the latest applied migration, 0 if none.
+func (d *Database) SchemaVersion() (int, error) {
+	var version int
+	err := d.db.Model(&models.SchemaVersion{}).Select("COALESCE(MAX(version), 0)").Scan(&version).Error
+	return version, err
+}
+
+// checkSchema makes sure the schema matches this binary. A brand new database
+// gets all migrations applied, an existing one has to be migrated explicitly.
+func (d *Database) checkSchema() error {
+	version, err := d.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case version > LatestSchemaVersion():
+		return fmt.Errorf("%w (database: %d, supported: %d)", ErrSchemaTooNew, version, LatestSchemaVersion())
+	case version == LatestSchemaVersion():
+		return nil
+	case version == 0 && !d.db.Migrator().HasTable("documents"):
+		return d.migrate()
+	default:
+		return fmt.Errorf("%w (database: %d, latest: %d)", ErrSchemaOutdated, version, LatestSchemaVersion())
+	}
+}
+
+func (d *Database) migrate() error {
+	current, err := d.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		err := d.db.Transaction(func(tx *gorm.DB) error {
+			if err := m.up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&models.SchemaVersion{
+				Version:   m.version,
+				Name:      m.name,
+				AppliedAt: time.Now(),
+			}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d (%s) failed, err: %w", m.version, m.name, err)
+		}
+	}
+	return nil
+}
+
+func createTableIfMissing(tx *gorm.DB, table any) error {
+	if tx.Migrator().HasTable(table) {
+		return nil
+	}
+	return tx.Migrator().CreateTable(table)
+}
+
+// Version 1 is the schema created by AutoMigrate before migrations existed,
+// so databases created by older versions are adopted as they are.
+
+type documentV1 struct {
+	ID         int    `gorm:"primaryKey"`
+	Path       string `gorm:"unique;index"`
+	TotalTerms uint
+}
+
+func (documentV1) TableName() string { return "documents" }
+
+type termV1 struct {
+	ID       int    `gorm:"primaryKey"`
+	Text     string `gorm:"unique;index"`
+	DocCount uint
+}
+
+func (termV1) TableName() string { return "terms" }
+
+type documentTermV1 struct {
+	DocumentID int `gorm:"primaryKey"`
+	TermID     int `gorm:"primaryKey"`
+	Count      uint
+}
+
+func (documentTermV1) TableName() string { return "document_terms" }
+
+func migrateInitialSchema(tx *gorm.DB) error {
+	for _, table := range []any{&documentV1{}, &termV1{}, &documentTermV1{}} {
+		if err := createTableIfMissing(tx, table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type documentV2 struct {
+	Collection string `gorm:"not null;default:default;index"`
+}
+
+func (documentV2) TableName() string { return "documents" }
+
+type collectionV2 struct {
+	ID            int    `gorm:"primaryKey"`
+	Name          string `gorm:"unique;not null"`
+	Root          string
+	LastIndexedAt time.Time
+}
+
+func (collectionV2) TableName() string { return "collections" }
+
+// migrateCollections puts all existing documents into the default collection.
+func migrateCollections(tx *gorm.DB) error {
+	if err := tx.Migrator().AddColumn(&documentV2{}, "Collection"); err != nil {
+		return err
+	}
+	if err := tx.Migrator().CreateIndex(&documentV2{}, "Collection"); err != nil {
+		return err
+	}
+	if err := createTableIfMissing(tx, &collectionV2{}); err != nil {
+		return err
+	}
+
+	var count int64
+	if err := tx.Table("documents").Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return nil
+	}
+	return tx.Create(&collectionV2{Name: models.DefaultCollection}).Error
+}
+
+type documentV3 struct {
+	ID        int `gorm:"primaryKey"`
+	Path      string
+	Extension string `gorm:"index"`
+	Dir       string `gorm:"index"`
+	ModTime   time.Time
+}
+
+func (documentV3) TableName() string { return "documents" }
+
+// migrateDocumentFacets adds extension, parent directory and modification time
+// to documents. Extension and directory are backfilled from the path,
+// the modification time stays unknown until the document is re-indexed.
+func migrateDocumentFacets(tx *gorm.DB) error {
+	for _, field := range []string{"Extension", "Dir", "ModTime"} {
+		if err := tx.Migrator().AddColumn(&documentV3{}, field); err != nil {
+			return err
+		}
+	}
+	for _, field := range []string{"Extension", "Dir"} {
+		if err := tx.Migrator().CreateIndex(&documentV3{}, field); err != nil {
+			return err
+		}
+	}
+
+	var documents []documentV3
+	return tx.Select("id", "path").FindInBatches(&documents, 1000, func(batch *gorm.DB, _ int) error {
+		for _, doc := range documents {
+			err := tx.Model(&documentV3{}).Where("id = ?", doc.ID).Updates(map[string]any{
+				"extension": extensionOf(doc.Path),
+				"dir":       dirOf(doc.Path),
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+type documentV4 struct {
+	ContentHash string `gorm:"index"`
+}
+
+func (documentV4) TableName() string { return "documents" }
+
+type documentTextV4 struct {
+	Hash string `gorm:"primaryKey"`
+	Data []byte
+	Size int
+}
+
+func (documentTextV4) TableName() string { return "document_texts" }
+
+// migrateDocumentTexts adds the extracted text store, existing documents
+// have no stored text until they are re-indexed.
+func migrateDocumentTexts(tx *gorm.DB) error {
+	if err := tx.Migrator().AddColumn(&documentV4{}, "ContentHash"); err != nil {
+		return err
+	}
+	if err := tx.Migrator().CreateIndex(&documentV4{}, "ContentHash"); err != nil {
+		return err
+	}
+	return createTableIfMissing(tx, &documentTextV4{})
+}
+
+type collectionV5 struct {
+	Analyzer string
+}
+
+func (collectionV5) TableName() string { return "collections" }
+
+// migrateCollectionAnalyzers adds the analyzer fingerprint to collections,
+// it stays unknown for existing collections until they are re-indexed.
+func migrateCollectionAnalyzers(tx *gorm.DB) error {
+	return tx.Migrator().AddColumn(&collectionV5{}, "Analyzer")
+}
+
+type documentV6 struct {
+	SimHash int64
+}
+
+func (documentV6) TableName() string { return "documents" }
+
+// migrateDocumentSignatures adds the SimHash of documents, existing
+// documents aren't checked for duplicates until they are re-indexed.
+func migrateDocumentSignatures(tx *gorm.DB) error {
+	return tx.Migrator().AddColumn(&documentV6{}, "SimHash")
+}
+
+type documentV7 struct {
+	Parent  string `gorm:"index"`
+	Passage string
+}
+
+func (documentV7) TableName() string { return "documents" }
+
+// migrateDocumentPassages adds the parent file and label of passages,
+// existing documents are files indexed as a whole.
+func migrateDocumentPassages(tx *gorm.DB) error {
+	if err := tx.Migrator().AddColumn(&documentV7{}, "Parent"); err != nil {
+		return err
+	}
+	if err := tx.Migrator().AddColumn(&documentV7{}, "Passage"); err != nil {
+		return err
+	}
+	return tx.Migrator().CreateIndex(&documentV7{}, "Parent")
+}
+
+type documentV8 struct {
+	Page int
+}
+
+func (documentV8) TableName() string { return "documents" }
+
+// migrateDocumentPages adds the PDF page number of page passages.
+func migrateDocumentPages(tx *gorm.DB) error {
+	return tx.Migrator().AddColumn(&documentV8{}, "Page")
+}
+
+type documentV9 struct {
+	NoText      bool `gorm:"not null;default:false"`
+	FailedPages int  `gorm:"not null;default:0"`
+}
+
+func (documentV9) TableName() string { return "documents" }
+
+// migrateDocumentExtraction adds the markers of files whose text couldn't
+// be (completely) extracted, existing documents are assumed to be fine.
+func migrateDocumentExtraction(tx *gorm.DB) error {
+	if err := tx.Migrator().AddColumn(&documentV9{}, "NoText"); err != nil {
+		return err
+	}
+	return tx.Migrator().AddColumn(&documentV9{}, "FailedPages")
+}
+
+type collectionRootV10 struct {
+	ID         int    `gorm:"primaryKey"`
+	Collection string `gorm:"not null;uniqueIndex:idx_collection_root"`
+	Root       string `gorm:"not null;uniqueIndex:idx_collection_root"`
+}
+
+func (collectionRootV10) TableName() string { return "collection_roots" }
+
+// migrateCollectionRoots adds all directories collections were indexed from,
+// existing collections only know the last one until they are re-indexed.
+func migrateCollectionRoots(tx *gorm.DB) error {
+	if err := createTableIfMissing(tx, &collectionRootV10{}); err != nil {
+		return err
+	}
+	var collections []collectionV2
+	if err := tx.Where("root <> ''").Find(&collections).Error; err != nil {
+		return err
+	}
+	for _, collection := range collections {
+		if err := tx.Create(&collectionRootV10{Collection: collection.Name, Root: collection.Root}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type termV11 struct {
+	Surface string `gorm:"not null;default:''"`
+}
+
+func (termV11) TableName() string { return "terms" }
+
+// migrateTermSurfaces adds the words terms were analyzed from, existing
+// terms are shown as they are until they are indexed again.
+func migrateTermSurfaces(tx *gorm.DB) error {
+	return tx.Migrator().AddColumn(&termV11{}, "Surface")
+}
+
+type documentTextV12 struct {
+	NoText      bool `gorm:"not null;default:false"`
+	FailedPages int  `gorm:"not null;default:0"`
+}
+
+func (documentTextV12) TableName() string { return "document_texts" }
+
+// migrateTextExtraction adds the problems extracting stored texts, taken
+// from the documents with the text's content.
+func migrateTextExtraction(tx *gorm.DB) error {
+	if err := tx.Migrator().AddColumn(&documentTextV12{}, "NoText"); err != nil {
+		return err
+	}
+	if err := tx.Migrator().AddColumn(&documentTextV12{}, "FailedPages"); err != nil {
+		return err
+	}
+	return tx.Exec(`UPDATE document_texts SET
+		no_text = EXISTS (SELECT 1 FROM documents WHERE documents.content_hash = document_texts.hash AND documents.no_text),
+		failed_pages = COALESCE((SELECT MAX(failed_pages) FROM documents WHERE documents.content_hash = document_texts.hash), 0)`).Error
+}
@@ -0,0 +1,127 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gfxv/scout/internal/models"
+)
+
+func TestMigrations_FreshDatabase(t *testing.T) {
+	for name, db := range testDatabases(t) {
+		t.Run(name, func(t *testing.T) {
+			version, err := db.SchemaVersion()
+			if err != nil {
+				t.Fatalf("SchemaVersion failed: %v", err)
+			}
+			if version != LatestSchemaVersion() {
+				t.Errorf("expected schema version %d, got %d", LatestSchemaVersion(), version)
+			}
+		})
+	}
+}
+
+func TestMigrations_RefuseNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "meta.db")
+	db, err := NewDatabase(path)
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	future := models.SchemaVersion{Version: LatestSchemaVersion() + 1, Name: "from the future", AppliedAt: time.Now()}
+	if err := db.db.Create(&future).Error; err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	if _, err := NewDatabase(path); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("expected ErrSchemaTooNew, got %v", err)
+	}
+	if _, _, err := Migrate(path); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("expected Migrate to fail with ErrSchemaTooNew, got %v", err)
+	}
+}
+
+func TestMigrations_AdoptUnversionedDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "meta.db")
+
+	// simulate a database created before migrations existed
+	legacy, err := connect(path, DefaultSQLiteOptions())
+	if err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	if err := legacy.db.Migrator().DropTable(&models.SchemaVersion{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrateInitialSchema(legacy.db); err != nil {
+		t.Fatal(err)
+	}
+	if err := legacy.db.Create(&documentV1{Path: "a.txt", TotalTerms: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	legacy.Close()
+
+	if _, err := NewDatabase(path); !errors.Is(err, ErrSchemaOutdated) {
+		t.Fatalf("expected ErrSchemaOutdated, got %v", err)
+	}
+
+	from, to, err := Migrate(path)
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if from != 0 || to != LatestSchemaVersion() {
+		t.Errorf("expected migration from 0 to %d, got %d to %d", LatestSchemaVersion(), from, to)
+	}
+
+	db, err := NewDatabase(path)
+	if err != nil {
+		t.Fatalf("NewDatabase after migration failed: %v", err)
+	}
+	defer db.Close()
+	total, err := db.GetTotalDocuments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 {
+		t.Errorf("expected existing document to survive migration, got %d documents", total)
+	}
+
+	collections, err := db.GetCollections()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(collections) != 1 || collections[0].Name != models.DefaultCollection || collections[0].Documents != 1 {
+		t.Errorf("expected existing document in the default collection, got %+v", collections)
+	}
+
+	docIndex, _, err := db.LoadIndexData()
+	if err != nil {
+		t.Fatalf("LoadIndexData after migration failed: %v", err)
+	}
+	if got := docIndex["a.txt"].Extension; got != ".txt" {
+		t.Errorf("expected backfilled extension %q, got %q", ".txt", got)
+	}
+}
+
+func TestMigrations_SQLiteOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "meta.db")
+	opts := DefaultSQLiteOptions()
+	opts.JournalMode = "DELETE"
+	if _, _, err := MigrateWithOptions(path, opts); err != nil {
+		t.Fatalf("MigrateWithOptions failed: %v", err)
+	}
+
+	// the file format version in the header is 2 in WAL mode, 1 otherwise
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) < 20 {
+		t.Fatalf("expected a SQLite header, got %d bytes", len(data))
+	}
+	if data[18] != 1 || data[19] != 1 {
+		t.Errorf("expected the database created in rollback journal mode, got header %v", data[18:20])
+	}
+}
@@ -0,0 +1,144 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// numSQLiteReaders is the size of the read-only connection pool. SQLite allows
+// a single writer only, so the write pool always has exactly one connection.
+const numSQLiteReaders = 4
+
+var (
+	journalModes = []string{"DELETE", "TRUNCATE", "PERSIST", "MEMORY", "WAL", "OFF"}
+	syncModes    = []string{"OFF", "NORMAL", "FULL", "EXTRA"}
+)
+
+// SQLiteOptions are applied as pragmas to every new SQLite connection.
+// See https://www.sqlite.org/pragma.html for their meaning.
+type SQLiteOptions struct {
+	JournalMode string
+	Synchronous string
+	// CacheSize in pages if positive, in KiB if negative.
+	CacheSize   int
+	MmapSize    int64
+	BusyTimeout time.Duration
+}
+
+// DefaultSQLiteOptions lets readers work alongside the indexer (WAL)
+// and trades a bit of durability on power loss for write speed.
+func DefaultSQLiteOptions() SQLiteOptions {
+	return SQLiteOptions{
+		JournalMode: "WAL",
+		Synchronous: "NORMAL",
+		CacheSize:   -64000,
+		MmapSize:    256 << 20,
+		BusyTimeout: 5 * time.Second,
+	}
+}
+
+func (o SQLiteOptions) Validate() error {
+	if !slices.Contains(journalModes, strings.ToUpper(o.JournalMode)) {
+		return fmt.Errorf("unknown journal mode %q (available: %s)", o.JournalMode, strings.Join(journalModes, ", "))
+	}
+	if !slices.Contains(syncModes, strings.ToUpper(o.Synchronous)) {
+		return fmt.Errorf("unknown synchronous mode %q (available: %s)", o.Synchronous, strings.Join(syncModes, ", "))
+	}
+	if o.MmapSize < 0 {
+		return fmt.Errorf("mmap size can't be negative")
+	}
+	if o.BusyTimeout < 0 {
+		return fmt.Errorf("busy timeout can't be negative")
+	}
+	return nil
+}
+
+func (o SQLiteOptions) pragmas(readOnly bool) []string {
+	pragmas := []string{
+		fmt.Sprintf("PRAGMA busy_timeout = %d", o.BusyTimeout.Milliseconds()),
+		fmt.Sprintf("PRAGMA journal_mode = %s", strings.ToUpper(o.JournalMode)),
+		fmt.Sprintf("PRAGMA synchronous = %s", strings.ToUpper(o.Synchronous)),
+		fmt.Sprintf("PRAGMA cache_size = %d", o.CacheSize),
+		fmt.Sprintf("PRAGMA mmap_size = %d", o.MmapSize),
+	}
+	if readOnly {
+		pragmas = append(pragmas, "PRAGMA query_only = ON")
+	}
+	return pragmas
+}
+
+var (
+	sqliteDriversMu sync.Mutex
+	sqliteDrivers   = make(map[string]string)
+)
+
+// sqliteDriver registers (once per distinct set of pragmas) a sqlite3 driver
+// running the pragmas on connect, so they apply to every pooled connection.
+func sqliteDriver(pragmas []string) string {
+	sqliteDriversMu.Lock()
+	defer sqliteDriversMu.Unlock()
+
+	key := strings.Join(pragmas, ";")
+	if name, ok := sqliteDrivers[key]; ok {
+		return name
+	}
+
+	name := fmt.Sprintf("sqlite3_scout_%d", len(sqliteDrivers))
+	sql.Register(name, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			for _, pragma := range pragmas {
+				if _, err := conn.Exec(pragma, nil); err != nil {
+					return fmt.Errorf("%s failed, err: %w", pragma, err)
+				}
+			}
+			return nil
+		},
+	})
+	sqliteDrivers[key] = name
+	return name
+}
+
+// openSQLite returns a single-connection write pool and a read-only pool.
+func openSQLite(path string, opts SQLiteOptions, config *gorm.Config) (*gorm.DB, *gorm.DB, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	writer, err := gorm.Open(sqlite.New(sqlite.Config{
+		DriverName: sqliteDriver(opts.pragmas(false)),
+		DSN:        path,
+	}), config)
+	if err != nil {
+		return nil, nil, err
+	}
+	writerDB, err := writer.DB()
+	if err != nil {
+		return nil, nil, err
+	}
+	writerDB.SetMaxOpenConns(1)
+
+	reader, err := gorm.Open(sqlite.New(sqlite.Config{
+		DriverName: sqliteDriver(opts.pragmas(true)),
+		DSN:        path,
+	}), config)
+	if err != nil {
+		writerDB.Close()
+		return nil, nil, err
+	}
+	readerDB, err := reader.DB()
+	if err != nil {
+		writerDB.Close()
+		return nil, nil, err
+	}
+	readerDB.SetMaxOpenConns(numSQLiteReaders)
+
+	return writer, reader, nil
+}
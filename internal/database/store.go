@@ -0,0 +1,33 @@
+package database
+
+import "github.com/gfxv/scout/internal/models"
+
+// IndexStore is the persistence layer used by the indexer.
+// Database (SQLite or PostgreSQL) and MemoryStore both implement it.
+type IndexStore interface {
+	// AddDocuments stores the documents, replacing the stored documents
+	// (or passages) of files that are indexed again.
+	AddDocuments(docs []DocumentData) error
+	RemoveDocument(path string) error
+	LoadIndexData() (models.DocIndex, models.TermFreq, error)
+	GetTotalDocuments() (int64, error)
+	// ReplaceTerms replaces the terms of all documents at once, e.g. after
+	// the analyzer changed. Every stored document must be given, by path.
+	ReplaceTerms(docs []DocumentData) error
+	// SaveCollection creates or updates the collection and marks it as just
+	// indexed with the analyzer with the given fingerprint.
+	SaveCollection(name, root, analyzer string) error
+	GetCollections() ([]models.CollectionStats, error)
+	// GetSurfaceForms returns the words terms were analyzed from, by term.
+	GetSurfaceForms() (map[string]string, error)
+	// GetText returns the text stored for the content hash, or ErrTextNotFound.
+	GetText(hash string) (string, error)
+	// GetStoredText is like GetText, but also returns the problems extracting it.
+	GetStoredText(hash string) (StoredText, error)
+	Close() error
+}
+
+var (
+	_ IndexStore = (*Database)(nil)
+	_ IndexStore = (*MemoryStore)(nil)
+)
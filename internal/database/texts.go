@@ -0,0 +1,128 @@
+package database
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/gfxv/scout/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrTextNotFound is returned by GetText when no text is stored for the hash.
+var ErrTextNotFound = errors.New("text not found")
+
+func compressText(text string) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write([]byte(text)); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decompressText(data []byte) (string, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	text, err := io.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+	return string(text), nil
+}
+
+// StoredText is the text extracted from files with some content, along
+// with the problems extracting it.
+type StoredText struct {
+	Text        string
+	NoText      bool
+	FailedPages int
+}
+
+// storedTexts compresses the texts of the documents that have one,
+// documents with the same content are stored once.
+func storedTexts(docs []DocumentData) ([]models.DocumentText, error) {
+	texts := make([]models.DocumentText, 0)
+	seen := make(map[string]bool)
+	for _, doc := range docs {
+		if doc.ContentHash == "" || doc.Text == "" || seen[doc.ContentHash] {
+			continue
+		}
+		seen[doc.ContentHash] = true
+
+		data, err := compressText(doc.Text)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compress text of %s, err: %w", doc.Path, err)
+		}
+		texts = append(texts, models.DocumentText{
+			Hash:        doc.ContentHash,
+			Data:        data,
+			Size:        len(doc.Text),
+			NoText:      doc.NoText,
+			FailedPages: doc.FailedPages,
+		})
+	}
+	return texts, nil
+}
+
+func (d *Database) insertTexts(tx *gorm.DB, docs []DocumentData) error {
+	texts, err := storedTexts(docs)
+	if err != nil {
+		return err
+	}
+	if len(texts) == 0 {
+		return nil
+	}
+	// the text may already be stored for another file with the same content
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&texts, chunkSize(3)).Error
+}
+
+// removeText deletes the stored text unless other documents still have the same content.
+func (d *Database) removeText(tx *gorm.DB, hash string) error {
+	if hash == "" {
+		return nil
+	}
+	var count int64
+	if err := tx.Model(&models.Document{}).Where("content_hash = ?", hash).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	return tx.Where("hash = ?", hash).Delete(&models.DocumentText{}).Error
+}
+
+func (d *Database) GetText(hash string) (string, error) {
+	text, err := d.GetStoredText(hash)
+	return text.Text, err
+}
+
+func (d *Database) GetStoredText(hash string) (StoredText, error) {
+	var text models.DocumentText
+	err := d.reader.Where("hash = ?", hash).Limit(1).Find(&text).Error
+	if err != nil {
+		return StoredText{}, err
+	}
+	if text.Hash == "" {
+		return StoredText{}, fmt.Errorf("%w: %s", ErrTextNotFound, hash)
+	}
+	return storedText(text)
+}
+
+// storedText decompresses the text.
+func storedText(text models.DocumentText) (StoredText, error) {
+	data, err := decompressText(text.Data)
+	if err != nil {
+		return StoredText{}, err
+	}
+	return StoredText{Text: data, NoText: text.NoText, FailedPages: text.FailedPages}, nil
+}
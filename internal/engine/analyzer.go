@@ -0,0 +1,320 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gfxv/scout/internal/models"
+	"github.com/kljensen/snowball/english"
+	"github.com/kljensen/snowball/french"
+	"github.com/kljensen/snowball/hungarian"
+	"github.com/kljensen/snowball/norwegian"
+	"github.com/kljensen/snowball/russian"
+	"github.com/kljensen/snowball/spanish"
+	"github.com/kljensen/snowball/swedish"
+)
+
+// AnalyzerConfig describes how text is turned into terms: the character
+// filters rewrite the text, the tokenizer splits it into tokens and the
+// token filters transform (or drop) the tokens, in the given order.
+// The same analyzer is used for indexing a collection and searching it.
+type AnalyzerConfig struct {
+	// CharFilters, see CharFilterNames.
+	CharFilters []string
+	// Tokenizer, see TokenizerNames, empty means "standard".
+	Tokenizer string
+	// Filters, see TokenFilterNames, nil means lowercase and stem.
+	Filters []string
+	// Language of the stem and stop filters, empty means Options.Language.
+	Language string
+	// StopWords are dropped by the stop filter along with the language's stop words.
+	StopWords []string
+	// MinLength and MaxLength are the bounds in runes of the length filter, 0 means no bound.
+	MinLength int
+	MaxLength int
+}
+
+type charFilter func(text []rune) []rune
+
+type tokenFilter func(tokens []string) []string
+
+var charFilters = map[string]func(config AnalyzerConfig) charFilter{
+	"strip_tags":      func(AnalyzerConfig) charFilter { return stripTags },
+	"fold_diacritics": func(AnalyzerConfig) charFilter { return foldDiacritics },
+}
+
+var tokenizers = map[string]func(text []rune) []string{
+	// runs of digits, runs of letters and digits, CJK bigrams and any other rune on its own
+	"standard": func(text []rune) []string {
+		// the text is already normalized by Analyze
+		tokenizer := &Tokenizer{data: text}
+		tokens := make([]string, 0)
+		for {
+			token, ok := tokenizer.nextRawToken()
+			if !ok {
+				return tokens
+			}
+			tokens = append(tokens, token)
+		}
+	},
+	"whitespace": func(text []rune) []string {
+		return strings.Fields(string(text))
+	},
+}
+
+var tokenFilters = map[string]func(config AnalyzerConfig) tokenFilter{
+	"lowercase": func(AnalyzerConfig) tokenFilter {
+		return mapTokens(strings.ToLower)
+	},
+	"stem": func(config AnalyzerConfig) tokenFilter {
+		stemmer := NewTokenizerWithLanguage(nil, config.Language)
+		return mapTokens(func(token string) string {
+			if !isWord(token) {
+				return token
+			}
+			return stemmer.stem(token)
+		})
+	},
+	"stop": func(config AnalyzerConfig) tokenFilter {
+		isStopWord := stopWords[config.Language]
+		extra := make(map[string]bool, len(config.StopWords))
+		for _, word := range config.StopWords {
+			extra[word] = true
+		}
+		return dropTokens(func(token string) bool {
+			return extra[token] || isStopWord(token)
+		})
+	},
+	"length": func(config AnalyzerConfig) tokenFilter {
+		return dropTokens(func(token string) bool {
+			n := utf8.RuneCountInString(token)
+			return n < config.MinLength || (config.MaxLength > 0 && n > config.MaxLength)
+		})
+	},
+	"punctuation": func(AnalyzerConfig) tokenFilter {
+		return dropTokens(func(token string) bool {
+			return strings.IndexFunc(token, func(r rune) bool {
+				return unicode.IsLetter(r) || unicode.IsDigit(r)
+			}) < 0
+		})
+	},
+}
+
+var stopWords = map[string]func(word string) bool{
+	"english":   english.IsStopWord,
+	"french":    french.IsStopWord,
+	"hungarian": hungarian.IsStopWord,
+	"norwegian": norwegian.IsStopWord,
+	"russian":   russian.IsStopWord,
+	"spanish":   spanish.IsStopWord,
+	"swedish":   swedish.IsStopWord,
+}
+
+var defaultTokenFilters = []string{"lowercase", "stem"}
+
+// normalizingFilters are the token filters applied to wildcard patterns,
+// they change how a word is written, not which word it is.
+var normalizingFilters = map[string]bool{"lowercase": true}
+
+func CharFilterNames() []string  { return sortedKeys(charFilters) }
+func TokenizerNames() []string   { return sortedKeys(tokenizers) }
+func TokenFilterNames() []string { return sortedKeys(tokenFilters) }
+
+func sortedKeys[T any](m map[string]T) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// analyzerVersion changes whenever a built-in filter or tokenizer
+// produces different terms, so it's part of the analyzer fingerprint.
+const analyzerVersion = 1
+
+// Analyzer turns text into terms, see AnalyzerConfig.
+type Analyzer struct {
+	charFilters []charFilter
+	tokenize    func(text []rune) []string
+	filters     []tokenFilter
+	// normalizers are the filters that are normalizingFilters
+	normalizers []tokenFilter
+	fingerprint string
+}
+
+// NewAnalyzer builds the pipeline described by config, language is used
+// unless the config has its own.
+func NewAnalyzer(config AnalyzerConfig, language string) (*Analyzer, error) {
+	config = config.withDefaults(language)
+	if err := ValidateLanguage(config.Language); err != nil {
+		return nil, err
+	}
+	if config.MinLength < 0 || config.MaxLength < 0 {
+		return nil, fmt.Errorf("token length bounds can't be negative")
+	}
+
+	analyzer := &Analyzer{fingerprint: config.fingerprint()}
+	for _, name := range config.CharFilters {
+		newFilter, ok := charFilters[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown char filter %q (available: %s)", name, strings.Join(CharFilterNames(), ", "))
+		}
+		analyzer.charFilters = append(analyzer.charFilters, newFilter(config))
+	}
+
+	tokenize, ok := tokenizers[config.Tokenizer]
+	if !ok {
+		return nil, fmt.Errorf("unknown tokenizer %q (available: %s)", config.Tokenizer, strings.Join(TokenizerNames(), ", "))
+	}
+	analyzer.tokenize = tokenize
+
+	for _, name := range config.Filters {
+		newFilter, ok := tokenFilters[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown token filter %q (available: %s)", name, strings.Join(TokenFilterNames(), ", "))
+		}
+		filter := newFilter(config)
+		analyzer.filters = append(analyzer.filters, filter)
+		if normalizingFilters[name] {
+			analyzer.normalizers = append(analyzer.normalizers, filter)
+		}
+	}
+	return analyzer, nil
+}
+
+func (c AnalyzerConfig) withDefaults(language string) AnalyzerConfig {
+	if c.Tokenizer == "" {
+		c.Tokenizer = "standard"
+	}
+	if c.Filters == nil {
+		c.Filters = defaultTokenFilters
+	}
+	if c.Language == "" {
+		c.Language = language
+	}
+	return c
+}
+
+// fingerprint identifies the terms produced by the config,
+// equal configs (after defaults) have equal fingerprints.
+func (c AnalyzerConfig) fingerprint() string {
+	data, _ := json.Marshal(struct {
+		Version int
+		Config  AnalyzerConfig
+	}{analyzerVersion, c})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Fingerprint changes whenever the analyzer would produce different terms,
+// so an index can be checked against the analyzer used for searching it.
+func (a *Analyzer) Fingerprint() string {
+	return a.fingerprint
+}
+
+// Analyze returns the terms of the NFKC normalized text.
+func (a *Analyzer) Analyze(text []rune) []string {
+	text = normalize(text)
+	for _, filter := range a.charFilters {
+		text = filter(text)
+	}
+	tokens := a.tokenize(text)
+	for _, filter := range a.filters {
+		tokens = filter(tokens)
+	}
+	return tokens
+}
+
+// normalizePattern prepares a wildcard pattern for matching the terms: it's
+// NFKC normalized, char filtered and lowercased like the text, but not
+// stemmed, as the pattern is only part of a word.
+func (a *Analyzer) normalizePattern(pattern string) string {
+	text := normalize([]rune(pattern))
+	for _, filter := range a.charFilters {
+		text = filter(text)
+	}
+	tokens := []string{string(text)}
+	for _, filter := range a.normalizers {
+		tokens = filter(tokens)
+	}
+	return tokens[0]
+}
+
+// SurfaceForms maps the terms of the text to a word they were analyzed from,
+// so terms can be shown to users, e.g. "kubernet" to "kubernetes". A word equal
+// to its term is preferred, otherwise the shortest one.
+func (a *Analyzer) SurfaceForms(text []rune) map[string]string {
+	text = normalize(text)
+	for _, filter := range a.charFilters {
+		text = filter(text)
+	}
+	forms := make(map[string]string)
+	seen := make(map[string]bool)
+	for _, token := range a.tokenize(text) {
+		if seen[token] {
+			continue
+		}
+		seen[token] = true
+		// the filters work token by token, so they can run on one at a time
+		terms := []string{token}
+		for _, filter := range a.filters {
+			terms = filter(terms)
+		}
+		if len(terms) != 1 {
+			continue
+		}
+		if word, form := strings.ToLower(token), forms[terms[0]]; form == "" || models.BetterSurfaceForm(terms[0], word, form) {
+			forms[terms[0]] = word
+		}
+	}
+	return forms
+}
+
+func mapTokens(f func(string) string) tokenFilter {
+	return func(tokens []string) []string {
+		for n, token := range tokens {
+			tokens[n] = f(token)
+		}
+		return tokens
+	}
+}
+
+func dropTokens(drop func(string) bool) tokenFilter {
+	return func(tokens []string) []string {
+		kept := tokens[:0]
+		for _, token := range tokens {
+			if !drop(token) {
+				kept = append(kept, token)
+			}
+		}
+		return kept
+	}
+}
+
+// stripTags replaces markup like <b> or </div> with a space.
+func stripTags(text []rune) []rune {
+	result := make([]rune, 0, len(text))
+	for n := 0; n < len(text); n++ {
+		if text[n] == '<' && n+1 < len(text) && isTagStart(text[n+1]) {
+			if end := slices.Index(text[n+1:], '>'); end >= 0 {
+				result = append(result, ' ')
+				n += end + 1
+				continue
+			}
+		}
+		result = append(result, text[n])
+	}
+	return result
+}
+
+func isTagStart(r rune) bool {
+	return unicode.IsLetter(r) || r == '/' || r == '!' || r == '?'
+}
@@ -0,0 +1,212 @@
+package engine
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAnalyzer_Analyze(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   AnalyzerConfig
+		content  string
+		expected []string
+	}{
+		{
+			name:     "Default pipeline",
+			config:   AnalyzerConfig{},
+			content:  "Running the Tests, 42 times!",
+			expected: []string{"run", "the", "test", ",", "42", "time", "!"},
+		},
+		{
+			name:     "Drop punctuation and stop words",
+			config:   AnalyzerConfig{Filters: []string{"lowercase", "punctuation", "stop", "stem"}},
+			content:  "Running the Tests, 42 times!",
+			expected: []string{"run", "test", "42", "time"},
+		},
+		{
+			name:     "Extra stop words are compared after earlier filters",
+			config:   AnalyzerConfig{Filters: []string{"lowercase", "stop"}, StopWords: []string{"lorem"}},
+			content:  "Lorem ipsum",
+			expected: []string{"ipsum"},
+		},
+		{
+			name:     "Length bounds",
+			config:   AnalyzerConfig{Filters: []string{"length"}, MinLength: 2, MaxLength: 4},
+			content:  "a bb ccc dddd eeeee",
+			expected: []string{"bb", "ccc", "dddd"},
+		},
+		{
+			name:     "No filters",
+			config:   AnalyzerConfig{Filters: []string{}},
+			content:  "Running Tests",
+			expected: []string{"Running", "Tests"},
+		},
+		{
+			name:     "Whitespace tokenizer",
+			config:   AnalyzerConfig{Tokenizer: "whitespace", Filters: []string{"lowercase"}},
+			content:  "v1.2.3 user@example.com  ERR-42",
+			expected: []string{"v1.2.3", "user@example.com", "err-42"},
+		},
+		{
+			name:     "Strip tags",
+			config:   AnalyzerConfig{CharFilters: []string{"strip_tags"}, Filters: []string{"punctuation"}},
+			content:  "<p>a <b>bold</b> claim</p> 1 < 2",
+			expected: []string{"a", "bold", "claim", "1", "2"},
+		},
+		{
+			name:     "Fold diacritics before stemming",
+			config:   AnalyzerConfig{CharFilters: []string{"fold_diacritics"}},
+			content:  "Déjà vu",
+			expected: []string{"deja", "vu"},
+		},
+		{
+			name:     "Language of the analyzer",
+			config:   AnalyzerConfig{Language: "french", Filters: []string{"stop", "stem"}},
+			content:  "des chevaux",
+			expected: []string{"cheval"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analyzer, err := NewAnalyzer(tt.config, DefaultLanguage)
+			if err != nil {
+				t.Fatalf("NewAnalyzer failed: %v", err)
+			}
+			if got := analyzer.Analyze([]rune(tt.content)); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestAnalyzer_SurfaceForms(t *testing.T) {
+	analyzer, err := NewAnalyzer(AnalyzerConfig{Filters: []string{"lowercase", "stop", "stem"}}, DefaultLanguage)
+	if err != nil {
+		t.Fatalf("NewAnalyzer failed: %v", err)
+	}
+	forms := analyzer.SurfaceForms([]rune("Kubernetes clusters, the Running cluster runs"))
+	expected := map[string]string{
+		"kubernet": "kubernetes",
+		// a word equal to its term is preferred
+		"cluster": "cluster",
+		// otherwise the shortest
+		"run": "runs",
+		",":   ",",
+	}
+	if !reflect.DeepEqual(forms, expected) {
+		t.Errorf("expected %v, got %v", expected, forms)
+	}
+}
+
+func TestAnalyzer_normalizePattern(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   AnalyzerConfig
+		pattern  string
+		expected string
+	}{
+		{name: "Default pipeline", config: AnalyzerConfig{}, pattern: "Running*", expected: "running*"},
+		{name: "Full-width", config: AnalyzerConfig{}, pattern: "ｋｕｂｅ＊", expected: "kube*"},
+		{name: "Folded", config: AnalyzerConfig{CharFilters: []string{"fold_diacritics"}}, pattern: "Crè?e*", expected: "cre?e*"},
+		{name: "Case kept", config: AnalyzerConfig{Filters: []string{"stem"}}, pattern: "Kube*", expected: "Kube*"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analyzer, err := NewAnalyzer(tt.config, DefaultLanguage)
+			if err != nil {
+				t.Fatalf("NewAnalyzer failed: %v", err)
+			}
+			if got := analyzer.normalizePattern(tt.pattern); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestAnalyzer_defaultMatchesTokenizer(t *testing.T) {
+	content := "Scout indexes PDFs, HTML & Markdown: 100% local! Ünïcode wörds 3rd"
+
+	analyzer, err := NewAnalyzer(AnalyzerConfig{}, DefaultLanguage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := make([]string, 0)
+	tokenizer := NewTokenizer([]rune(content))
+	for token, ok := tokenizer.NextToken(); ok; token, ok = tokenizer.NextToken() {
+		expected = append(expected, token)
+	}
+
+	if got := analyzer.Analyze([]rune(content)); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected default analyzer to match existing indexes %q, got %q", expected, got)
+	}
+}
+
+func TestNewAnalyzer_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config AnalyzerConfig
+	}{
+		{name: "Unknown char filter", config: AnalyzerConfig{CharFilters: []string{"nope"}}},
+		{name: "Unknown tokenizer", config: AnalyzerConfig{Tokenizer: "nope"}},
+		{name: "Unknown token filter", config: AnalyzerConfig{Filters: []string{"nope"}}},
+		{name: "Unknown language", config: AnalyzerConfig{Language: "klingon"}},
+		{name: "Negative length", config: AnalyzerConfig{MinLength: -1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewAnalyzer(tt.config, DefaultLanguage); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestIndexer_CollectionAnalyzers(t *testing.T) {
+	opts := DefaultOptions()
+	opts.CollectionAnalyzers = map[string]AnalyzerConfig{
+		"exact": {Tokenizer: "whitespace", Filters: []string{"lowercase"}},
+	}
+	indexer, err := NewIndexerWithOptions(newTestStore(), opts)
+	if err != nil {
+		t.Fatalf("NewIndexerWithOptions failed: %v", err)
+	}
+
+	stemmed := writeFiles(t, map[string]string{"a.md": "running v1.2.3"})
+	exact := writeFiles(t, map[string]string{"b.md": "running v1.2.3"})
+	if err := indexer.IndexDir("stemmed", stemmed); err != nil {
+		t.Fatal(err)
+	}
+	if err := indexer.IndexDir("exact", exact); err != nil {
+		t.Fatal(err)
+	}
+	if err := indexer.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	if terms := indexer.documentIndex[filepath.Join(exact, "b.md")].Terms; terms["v1.2.3"] != 1 || terms["running"] != 1 {
+		t.Errorf("expected whitespace analyzed terms, got %v", terms)
+	}
+
+	// each collection is searched with its own analyzer
+	for _, query := range []string{"running", "RUNNING"} {
+		got := make([]string, 0)
+		for _, result := range indexer.SearchQuery(query) {
+			if result.Rank() > 0 {
+				got = append(got, result.Collection())
+			}
+		}
+		if !sameElements(got, []string{"stemmed", "exact"}) {
+			t.Errorf("expected %q to match in both collections, got %q", query, got)
+		}
+	}
+
+	opts.CollectionAnalyzers = map[string]AnalyzerConfig{"Bad Name": {}}
+	if err := opts.Validate(); err == nil {
+		t.Error("expected error for an invalid collection name")
+	}
+}
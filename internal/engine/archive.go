@@ -0,0 +1,303 @@
+package engine
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// archiveSeparator separates an archive's path from the path of a file
+	// inside it in virtual paths, e.g. "bundle.zip!/docs/a.md".
+	archiveSeparator = "!/"
+	// defaultArchiveMaxEntrySize limits the size of a file extracted from an archive
+	defaultArchiveMaxEntrySize = 64 << 20
+	// defaultArchiveMaxSize limits how much is extracted from an archive while indexing it
+	defaultArchiveMaxSize = 1 << 30
+)
+
+var (
+	errStopWalk      = errors.New("stop walking the archive")
+	errEntryTooLarge = errors.New("file too large")
+)
+
+// isArchive reports whether the file is an archive indexed file by file.
+func isArchive(path string) bool {
+	return archiveKind(path) != ""
+}
+
+// archiveKind returns "zip" or "tar.gz" for the supported archives, "" otherwise.
+func archiveKind(path string) string {
+	name := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return "zip"
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return "tar.gz"
+	}
+	return ""
+}
+
+// splitArchivePath splits a virtual path into the archive's path and the
+// path of the file inside it, ok is false for paths of regular files.
+func splitArchivePath(virtual string) (archive, entry string, ok bool) {
+	archive, entry, ok = strings.Cut(virtual, archiveSeparator)
+	if !ok || !isArchive(archive) {
+		return "", "", false
+	}
+	return archive, entry, true
+}
+
+// entryName normalizes the name of a file in an archive to a relative
+// slash separated path, e.g. "./docs/../a.md" to "a.md".
+func entryName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// archiveEntry is a regular file in an archive, open returns its content.
+type archiveEntry struct {
+	name string
+	info fs.FileInfo
+	open func() (io.ReadCloser, error)
+}
+
+// walkArchive calls fn for every regular file in the archive, in order, until
+// it returns an error. errStopWalk stops the walk without failing it. Of files
+// with the same name (after entryName) only the last one is walked, the one
+// extracting the archive would keep. Entries can only be opened during the call.
+func walkArchive(archive string, fn func(entry archiveEntry) error) error {
+	var walk func(archive string, fn func(entry archiveEntry) error) error
+	switch archiveKind(archive) {
+	case "zip":
+		walk = walkZip
+	case "tar.gz":
+		walk = walkTarGz
+	default:
+		return fmt.Errorf("unsupported archive %s", archive)
+	}
+
+	// a first pass finds the last entry of every name, a tar can't be
+	// read backwards
+	last := make(map[string]int)
+	n := 0
+	err := walk(archive, func(entry archiveEntry) error {
+		last[entry.name] = n
+		n++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	n = 0
+	err = walk(archive, func(entry archiveEntry) error {
+		current := n
+		n++
+		if last[entry.name] != current {
+			return nil
+		}
+		return fn(entry)
+	})
+	if errors.Is(err, errStopWalk) {
+		return nil
+	}
+	return err
+}
+
+func walkZip(archive string, fn func(entry archiveEntry) error) error {
+	reader, err := zip.OpenReader(archive)
+	if err != nil {
+		return fmt.Errorf("failed to open archive %s, err: %w", archive, err)
+	}
+	defer reader.Close()
+
+	for _, file := range reader.File {
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		if err := fn(archiveEntry{name: entryName(file.Name), info: file.FileInfo(), open: file.Open}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func walkTarGz(archive string, fn func(entry archiveEntry) error) error {
+	file, err := os.Open(archive)
+	if err != nil {
+		return fmt.Errorf("failed to open archive %s, err: %w", archive, err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("failed to decompress archive %s, err: %w", archive, err)
+	}
+	defer gz.Close()
+
+	reader := tar.NewReader(gz)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive %s, err: %w", archive, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		open := func() (io.ReadCloser, error) { return io.NopCloser(reader), nil }
+		if err := fn(archiveEntry{name: entryName(header.Name), info: header.FileInfo(), open: open}); err != nil {
+			return err
+		}
+	}
+}
+
+// extractEntry copies the content of the entry into a temporary file with
+// the same extension, so readers and external commands can read it like any
+// other file, and returns its path and size. Entries larger than limit bytes
+// fail with errEntryTooLarge. The caller removes the file.
+func extractEntry(entry archiveEntry, limit int64) (string, int64, error) {
+	content, err := entry.open()
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to open %s, err: %w", entry.name, err)
+	}
+	defer content.Close()
+
+	temp, err := os.CreateTemp("", "scout-*"+filepath.Ext(entry.name))
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create temporary file, err: %w", err)
+	}
+	defer temp.Close()
+
+	// the size in the header can't be trusted, e.g. for zip bombs
+	written, err := io.Copy(temp, io.LimitReader(content, limit+1))
+	if err == nil && written > limit {
+		err = fmt.Errorf("%w, larger than %d bytes", errEntryTooLarge, limit)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		return "", 0, fmt.Errorf("failed to extract %s, err: %w", entry.name, err)
+	}
+	return temp.Name(), written, nil
+}
+
+// extractVirtual extracts the file at the virtual path from its archive
+// into a temporary file, see extractEntry.
+func extractVirtual(virtual string, limit int64) (string, error) {
+	archive, name, ok := splitArchivePath(virtual)
+	if !ok {
+		return "", fmt.Errorf("%s is not in an archive", virtual)
+	}
+	local := ""
+	err := walkArchive(archive, func(entry archiveEntry) error {
+		if entry.name != name {
+			return nil
+		}
+		var err error
+		if local, _, err = extractEntry(entry, limit); err != nil {
+			return err
+		}
+		return errStopWalk
+	})
+	if err != nil {
+		return "", err
+	}
+	if local == "" {
+		return "", fmt.Errorf("%w: %s", fs.ErrNotExist, virtual)
+	}
+	return local, nil
+}
+
+// localFile returns the path of a file on disk with the content of the file
+// at path, which is extracted from its archive for virtual paths. cleanup
+// removes it again.
+func (i *Indexer) localFile(path string) (local string, cleanup func(), err error) {
+	if _, _, ok := splitArchivePath(path); !ok {
+		return path, func() {}, nil
+	}
+	local, err = extractVirtual(path, i.archiveEntryLimit())
+	if err != nil {
+		return "", nil, err
+	}
+	return local, func() { os.Remove(local) }, nil
+}
+
+// archiveEntryLimit is the size files extracted from archives are limited to.
+func (i *Indexer) archiveEntryLimit() int64 {
+	limit := i.opts.ArchiveMaxEntrySize
+	if limit <= 0 {
+		limit = defaultArchiveMaxEntrySize
+	}
+	if i.opts.MaxFileSize > 0 {
+		limit = min(limit, i.opts.MaxFileSize)
+	}
+	return limit
+}
+
+// archiveSizeLimit is how much may be extracted from an archive while indexing it.
+func (i *Indexer) archiveSizeLimit() int64 {
+	if i.opts.ArchiveMaxSize > 0 {
+		return i.opts.ArchiveMaxSize
+	}
+	return defaultArchiveMaxSize
+}
+
+// indexArchive indexes the files in the archive matching match (all if nil)
+// under their virtual paths. Files failing to index are reported and skipped,
+// once archiveSizeLimit bytes were extracted the rest of the archive is.
+func (i *Indexer) indexArchive(collection, archive string, match func(path string) bool) error {
+	fmt.Printf("Indexing archive %s...\n", archive)
+	entryLimit, remaining := i.archiveEntryLimit(), i.archiveSizeLimit()
+	tooLarge := fmt.Errorf("archive %s extracts to more than %d bytes, skipped the rest of it", archive, i.archiveSizeLimit())
+	return walkArchive(archive, func(entry archiveEntry) error {
+		virtual := archive + archiveSeparator + entry.name
+		if match != nil && !match(virtual) {
+			return nil
+		}
+		if _, ok := i.readerFor(virtual); !ok {
+			fmt.Printf("Unknown file type %s\n", virtual)
+			return nil
+		}
+		if entry.info.Size() > entryLimit {
+			fmt.Printf("Skipping %s, file is larger than %d bytes\n", virtual, entryLimit)
+			return nil
+		}
+		if remaining <= 0 {
+			return tooLarge
+		}
+
+		local, size, err := extractEntry(entry, min(entryLimit, remaining))
+		if errors.Is(err, errEntryTooLarge) && remaining < entryLimit {
+			return tooLarge
+		}
+		if err != nil {
+			fmt.Println(err)
+			return nil
+		}
+		defer os.Remove(local)
+		remaining -= size
+		if err := i.indexFile(collection, virtual, local, entry.info); err != nil {
+			fmt.Println(err)
+		}
+		return nil
+	})
+}
+
+// tempFile is a temporary file removed when it's closed.
+type tempFile struct {
+	*os.File
+}
+
+func (f tempFile) Close() error {
+	err := f.File.Close()
+	os.Remove(f.Name())
+	return err
+}
@@ -0,0 +1,304 @@
+package engine
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gfxv/scout/internal/models"
+)
+
+// archiveFile is a file written to a test archive, in order.
+type archiveFile struct {
+	name    string
+	content string
+}
+
+func sortedFiles(files map[string]string) []archiveFile {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	sorted := make([]archiveFile, 0, len(files))
+	for _, name := range names {
+		sorted = append(sorted, archiveFile{name: name, content: files[name]})
+	}
+	return sorted
+}
+
+func writeZip(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	writeZipFiles(t, path, sortedFiles(files))
+}
+
+func writeZipFiles(t *testing.T, path string, files []archiveFile) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	writer := zip.NewWriter(file)
+	for _, f := range files {
+		entry, err := writer.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := entry.Write([]byte(f.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTarGz(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	writeTarGzFiles(t, path, sortedFiles(files))
+}
+
+func writeTarGzFiles(t *testing.T, path string, files []archiveFile) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	gz := gzip.NewWriter(file)
+	writer := tar.NewWriter(gz)
+	for _, f := range files {
+		header := &tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.content)), Typeflag: tar.TypeReg}
+		if err := writer.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writer.Write([]byte(f.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSplitArchivePath(t *testing.T) {
+	tests := []struct {
+		path    string
+		archive string
+		entry   string
+		ok      bool
+	}{
+		{path: "/docs/bundle.zip!/guides/a.md", archive: "/docs/bundle.zip", entry: "guides/a.md", ok: true},
+		{path: "/docs/logs.TGZ!/a.log", archive: "/docs/logs.TGZ", entry: "a.log", ok: true},
+		{path: "/docs/bundle.tar.gz!/a.md", archive: "/docs/bundle.tar.gz", entry: "a.md", ok: true},
+		{path: "/docs/wow!/a.md", ok: false},
+		{path: "/docs/bundle.zip", ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			archive, entry, ok := splitArchivePath(tt.path)
+			if archive != tt.archive || entry != tt.entry || ok != tt.ok {
+				t.Errorf("expected %q, %q, %v, got %q, %q, %v", tt.archive, tt.entry, tt.ok, archive, entry, ok)
+			}
+		})
+	}
+}
+
+func TestEntryName(t *testing.T) {
+	tests := map[string]string{
+		"docs/a.md":         "docs/a.md",
+		"./docs/a.md":       "docs/a.md",
+		"/docs/a.md":        "docs/a.md",
+		"../../etc/passwd":  "etc/passwd",
+		"docs/../../a.md":   "a.md",
+		"docs//nested/a.md": "docs/nested/a.md",
+	}
+	for name, want := range tests {
+		if got := entryName(name); got != want {
+			t.Errorf("expected %q for %q, got %q", want, name, got)
+		}
+	}
+}
+
+func TestIndexer_Archives(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"notes.md": "plain notes"})
+	bundle := filepath.Join(dir, "bundle.zip")
+	writeZip(t, bundle, map[string]string{
+		"docs/a.md":    "install the telescope",
+		"docs/huge.md": strings.Repeat("telescope ", 100),
+		"image.png":    "not text",
+	})
+	logs := filepath.Join(dir, "logs.tar.gz")
+	writeTarGz(t, logs, map[string]string{"./server.md": "telescope crashed at night"})
+
+	opts := DefaultOptions()
+	opts.Archives = true
+	opts.MaxFileSize = 100
+	indexer, err := NewIndexerWithOptions(newTestStore(), opts)
+	if err != nil {
+		t.Fatalf("NewIndexerWithOptions failed: %v", err)
+	}
+	source := Source{Collection: models.DefaultCollection, Root: dir, Include: []string{"*.md"}}
+	if err := indexer.IndexSource(source); err != nil {
+		t.Fatalf("IndexSource failed: %v", err)
+	}
+	if err := indexer.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	paths := make([]string, 0)
+	for path := range indexer.documentIndex {
+		paths = append(paths, strings.TrimPrefix(path, dir+string(filepath.Separator)))
+	}
+	if len(paths) != 3 {
+		t.Fatalf("expected notes.md and the Markdown files in the archives, got %v", paths)
+	}
+
+	results := indexer.SearchQuery("telescope")
+	found := make(map[string]int)
+	for _, result := range results {
+		if result.Rank() > 0 {
+			found[result.Path()] = result.ID()
+		}
+	}
+	entry := bundle + archiveSeparator + "docs/a.md"
+	if _, ok := found[entry]; !ok || len(found) != 2 {
+		t.Fatalf("expected %s and the log to match, got %v", entry, results)
+	}
+	if _, ok := found[logs+archiveSeparator+"server.md"]; !ok {
+		t.Errorf("expected the normalized path of server.md in %s", logs)
+	}
+
+	view, err := indexer.DocumentView(found[entry], "telescope")
+	if err != nil {
+		t.Fatalf("DocumentView failed: %v", err)
+	}
+	if view.Path != entry || len(view.Passages) != 1 {
+		t.Errorf("expected the view of %s, got %s", entry, view.Path)
+	}
+
+	file, path, err := indexer.OpenDocumentFile(found[entry])
+	if err != nil {
+		t.Fatalf("OpenDocumentFile failed: %v", err)
+	}
+	content, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	file.Close()
+	if want, _ := resolvePath(bundle); path != want+archiveSeparator+"docs/a.md" {
+		t.Errorf("expected %s in %s, got %s", "docs/a.md", want, path)
+	}
+	if string(content) != "install the telescope" {
+		t.Errorf("expected the content of docs/a.md, got %q", content)
+	}
+	if _, err := os.Stat(file.(tempFile).Name()); !os.IsNotExist(err) {
+		t.Errorf("expected the extracted file to be removed, got %v", err)
+	}
+}
+
+func TestExtractVirtual_Limit(t *testing.T) {
+	bundle := filepath.Join(t.TempDir(), "bundle.zip")
+	writeZip(t, bundle, map[string]string{"a.txt": "0123456789"})
+
+	if _, err := extractVirtual(bundle+archiveSeparator+"a.txt", 5); err == nil || !strings.Contains(err.Error(), "larger than 5 bytes") {
+		t.Errorf("expected the limit to fail the extraction, got %v", err)
+	}
+	if _, err := extractVirtual(bundle+archiveSeparator+"missing.txt", 0); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected a missing file, got %v", err)
+	}
+	local, err := extractVirtual(bundle+archiveSeparator+"a.txt", 10)
+	if err != nil {
+		t.Fatalf("extractVirtual failed: %v", err)
+	}
+	defer os.Remove(local)
+	if filepath.Ext(local) != ".txt" {
+		t.Errorf("expected the extension kept, got %s", local)
+	}
+}
+
+func TestWalkArchive_DuplicateNames(t *testing.T) {
+	dir := t.TempDir()
+	files := []archiveFile{
+		{name: "./a.md", content: "first"},
+		{name: "b.md", content: "other"},
+		{name: "a.md", content: "second"},
+	}
+	for _, archive := range []string{filepath.Join(dir, "bundle.zip"), filepath.Join(dir, "bundle.tar.gz")} {
+		t.Run(filepath.Base(archive), func(t *testing.T) {
+			if strings.HasSuffix(archive, ".zip") {
+				writeZipFiles(t, archive, files)
+			} else {
+				writeTarGzFiles(t, archive, files)
+			}
+
+			walked := make([]string, 0)
+			err := walkArchive(archive, func(entry archiveEntry) error {
+				content, err := entry.open()
+				if err != nil {
+					return err
+				}
+				defer content.Close()
+				data, err := io.ReadAll(content)
+				walked = append(walked, entry.name+":"+string(data))
+				return err
+			})
+			if err != nil {
+				t.Fatalf("walkArchive failed: %v", err)
+			}
+			if got := strings.Join(walked, ","); got != "b.md:other,a.md:second" {
+				t.Errorf("expected the last a.md only, got %q", got)
+			}
+		})
+	}
+}
+
+func TestIndexer_ArchiveLimits(t *testing.T) {
+	dir := t.TempDir()
+	bundle := filepath.Join(dir, "bundle.tar.gz")
+	writeTarGzFiles(t, bundle, []archiveFile{
+		{name: "a.md", content: strings.Repeat("a", 40)},
+		{name: "huge.md", content: strings.Repeat("h", 100)},
+		{name: "b.md", content: strings.Repeat("b", 40)},
+		{name: "c.md", content: strings.Repeat("c", 40)},
+	})
+
+	opts := DefaultOptions()
+	opts.Archives = true
+	opts.ArchiveMaxEntrySize = 50
+	opts.ArchiveMaxSize = 100
+	indexer, err := NewIndexerWithOptions(newTestStore(), opts)
+	if err != nil {
+		t.Fatalf("NewIndexerWithOptions failed: %v", err)
+	}
+	err = indexer.IndexFile(models.DefaultCollection, bundle)
+	if err == nil || !strings.Contains(err.Error(), "more than 100 bytes") {
+		t.Errorf("expected the archive to exceed its limit, got %v", err)
+	}
+	indexer.Flush()
+	if err := indexer.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	// huge.md is too large, c.md doesn't fit into the archive's limit
+	for _, name := range []string{"a.md", "b.md"} {
+		if _, ok := indexer.documentIndex[bundle+archiveSeparator+name]; !ok {
+			t.Errorf("expected %s to be indexed", name)
+		}
+	}
+	if len(indexer.documentIndex) != 2 {
+		t.Errorf("expected 2 documents, got %d", len(indexer.documentIndex))
+	}
+}
@@ -0,0 +1,138 @@
+package engine
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// maxQueryHistory is the number of distinct past queries kept for autocompletion.
+const maxQueryHistory = 1000
+
+// minCompletionPrefix is the shortest last word completed from the term dictionary.
+const minCompletionPrefix = 2
+
+// queryHistory counts past queries, it is kept in memory only.
+type queryHistory struct {
+	mu     *sync.Mutex
+	counts map[string]int
+}
+
+func newQueryHistory() *queryHistory {
+	return &queryHistory{
+		mu:     &sync.Mutex{},
+		counts: make(map[string]int),
+	}
+}
+
+func (h *queryHistory) record(query string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.counts[query]; !ok && len(h.counts) >= maxQueryHistory {
+		h.evictLeastUsed()
+	}
+	h.counts[query]++
+}
+
+func (h *queryHistory) evictLeastUsed() {
+	least, leastCount := "", 0
+	for query, count := range h.counts {
+		if least == "" || count < leastCount || (count == leastCount && query < least) {
+			least, leastCount = query, count
+		}
+	}
+	delete(h.counts, least)
+}
+
+// withPrefix returns the past queries starting with prefix, most frequent first.
+func (h *queryHistory) withPrefix(prefix string) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	queries := make([]string, 0)
+	for query := range h.counts {
+		if strings.HasPrefix(query, prefix) && query != prefix {
+			queries = append(queries, query)
+		}
+	}
+	sort.Slice(queries, func(a, b int) bool {
+		if h.counts[queries[a]] != h.counts[queries[b]] {
+			return h.counts[queries[a]] > h.counts[queries[b]]
+		}
+		return queries[a] < queries[b]
+	})
+	return queries
+}
+
+func normalizeQuery(query string) string {
+	return strings.ToLower(strings.Join(strings.Fields(query), " "))
+}
+
+// RecordQuery remembers a submitted query for Autocomplete.
+func (i *Indexer) RecordQuery(query string) {
+	if query = normalizeQuery(query); query != "" {
+		i.history.record(query)
+	}
+}
+
+// Autocomplete returns up to limit completions of a partially typed query:
+// past queries starting with it, most frequent first, followed by the query
+// with its last word completed from the indexed terms of the collections.
+func (i *Indexer) Autocomplete(partial string, limit int, collections ...string) []string {
+	prefix := normalizeQuery(partial)
+	if prefix == "" || limit <= 0 {
+		return []string{}
+	}
+
+	completions := i.history.withPrefix(prefix)
+	seen := make(map[string]bool, len(completions))
+	for _, completion := range completions {
+		seen[completion] = true
+	}
+
+	// a trailing space means the last word is complete
+	if !strings.HasSuffix(partial, " ") {
+		for _, completion := range i.completeLastWord(prefix, collections) {
+			if !seen[completion] {
+				completions = append(completions, completion)
+				seen[completion] = true
+			}
+		}
+	}
+
+	if len(completions) > limit {
+		completions = completions[:limit]
+	}
+	return completions
+}
+
+// completeLastWord replaces the last word of the query with the words of
+// indexed terms it is a prefix of, the ones found in most documents first.
+// Words are completed rather than terms, which may be stemmed.
+func (i *Indexer) completeLastWord(query string, collections []string) []string {
+	words := strings.Fields(query)
+	last := words[len(words)-1]
+	if utf8.RuneCountInString(last) < minCompletionPrefix || strings.ContainsAny(last, ":*?") {
+		return nil
+	}
+
+	scope := i.newSearchScope(collections)
+	matches := make([]string, 0)
+	for _, word := range i.words.words.withPrefix(last) {
+		if word != last && scope.docFrequency(i.words.terms[word]) > 0 {
+			matches = append(matches, word)
+		}
+	}
+	sort.SliceStable(matches, func(a, b int) bool {
+		return scope.docFrequency(i.words.terms[matches[a]]) > scope.docFrequency(i.words.terms[matches[b]])
+	})
+
+	head := strings.Join(words[:len(words)-1], " ")
+	completions := make([]string, 0, len(matches))
+	for _, word := range matches {
+		completions = append(completions, strings.TrimSpace(head+" "+word))
+	}
+	return completions
+}
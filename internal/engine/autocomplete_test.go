@@ -0,0 +1,89 @@
+package engine
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestIndexer_Autocomplete(t *testing.T) {
+	indexer, _ := newMemoryIndexer(t, map[string]string{
+		"a.md": "kubernetes kubelet",
+		"b.md": "kubernetes cluster",
+		"c.md": "lunch menu",
+	})
+	indexer.RecordQuery("kubernetes   Upgrade")
+	indexer.RecordQuery("kubernetes upgrade")
+	indexer.RecordQuery("kubelet logs")
+	indexer.RecordQuery("lunch")
+
+	tests := []struct {
+		name     string
+		partial  string
+		limit    int
+		expected []string
+	}{
+		{
+			name:    "history first, then terms",
+			partial: "Kube",
+			limit:   10,
+			// words, not the stemmed terms
+			expected: []string{"kubernetes upgrade", "kubelet logs", "kubernetes", "kubelet"},
+		},
+		{
+			name:     "limit",
+			partial:  "kube",
+			limit:    1,
+			expected: []string{"kubernetes upgrade"},
+		},
+		{
+			name:     "last word is completed",
+			partial:  "deploy clu",
+			limit:    10,
+			expected: []string{"deploy cluster"},
+		},
+		{
+			name:     "trailing space completes from history only",
+			partial:  "kubernetes ",
+			limit:    10,
+			expected: []string{"kubernetes upgrade"},
+		},
+		{
+			name:     "too short for terms",
+			partial:  "l",
+			limit:    10,
+			expected: []string{"lunch"},
+		},
+		{
+			name:     "empty",
+			partial:  "  ",
+			limit:    10,
+			expected: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := indexer.Autocomplete(tt.partial, tt.limit)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestQueryHistory_evict(t *testing.T) {
+	history := newQueryHistory()
+	history.record("popular")
+	history.record("popular")
+	for n := 0; n < maxQueryHistory; n++ {
+		history.record(fmt.Sprintf("query %d", n))
+	}
+
+	if len(history.counts) != maxQueryHistory {
+		t.Errorf("expected %d queries, got %d", maxQueryHistory, len(history.counts))
+	}
+	if history.counts["popular"] != 2 {
+		t.Error("expected the most used query to be kept")
+	}
+}
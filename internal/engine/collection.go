@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/gfxv/scout/internal/models"
+)
+
+var collectionNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// ValidateCollectionName allows lowercase letters, digits, '-' and '_',
+// so names are safe to use in URLs and config files.
+func ValidateCollectionName(name string) error {
+	if !collectionNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid collection name %q: use lowercase letters, digits, '-' and '_'", name)
+	}
+	return nil
+}
+
+// searchScope is the set of collections a query runs against.
+type searchScope struct {
+	// nil means all collections
+	collections map[string]bool
+	freqs       []models.TermFreq
+	global      models.TermFreq
+	totalDocs   int
+}
+
+func (i *Indexer) newSearchScope(collections []string) searchScope {
+	if len(collections) == 0 {
+		return searchScope{global: i.docFrequency, totalDocs: len(i.documentIndex)}
+	}
+
+	scope := searchScope{collections: make(map[string]bool)}
+	for _, collection := range collections {
+		if scope.collections[collection] {
+			continue
+		}
+		scope.collections[collection] = true
+		if freq, ok := i.collectionFreq[collection]; ok {
+			scope.freqs = append(scope.freqs, freq)
+		}
+		scope.totalDocs += i.collectionSize[collection]
+	}
+	return scope
+}
+
+func (s searchScope) contains(collection string) bool {
+	return s.collections == nil || s.collections[collection]
+}
+
+func (s searchScope) docFrequency(term string) uint {
+	if s.collections == nil {
+		return s.global[term]
+	}
+	var total uint
+	for _, freq := range s.freqs {
+		total += freq[term]
+	}
+	return total
+}
+
+func buildCollectionFrequency(docIndex models.DocIndex) (map[string]models.TermFreq, map[string]int) {
+	collectionFreq := make(map[string]models.TermFreq)
+	collectionSize := make(map[string]int)
+	for _, docInfo := range docIndex {
+		freq, ok := collectionFreq[docInfo.Collection]
+		if !ok {
+			freq = make(models.TermFreq)
+			collectionFreq[docInfo.Collection] = freq
+		}
+		for term := range docInfo.Terms {
+			freq[term]++
+		}
+		collectionSize[docInfo.Collection]++
+	}
+	return collectionFreq, collectionSize
+}
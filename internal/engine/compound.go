@@ -0,0 +1,98 @@
+package engine
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxCompoundLength is the longest compound token looked for, in runes.
+const maxCompoundLength = 256
+
+// compoundPatterns match tokens that are searched for as a whole,
+// e.g. versions or addresses, at the start of the text.
+var compoundPatterns = []*regexp.Regexp{
+	// URLs
+	regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*://[^\s<>"'{}|\\^` + "`" + `]+`),
+	// emails
+	regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9-]+(\.[a-zA-Z0-9-]+)+`),
+	// decimals, IPv4 addresses and versions, e.g. 3.14, 192.168.0.1, v1.23.3 or 2.0.0-rc.1
+	regexp.MustCompile(`^[vV]?\d+(\.\d+)+(-[0-9a-zA-Z]+(\.[0-9a-zA-Z]+)*)?(\+[0-9a-zA-Z.-]+)?`),
+	// codes and identifiers, e.g. ERR-4012, x86_64 or max_file_size
+	regexp.MustCompile(`^[\p{L}\p{N}]+([-_][\p{L}\p{N}]+)+`),
+}
+
+// compoundLength returns the length in runes of the compound token at the
+// start of data, 0 if there is none.
+func compoundLength(data []rune) int {
+	// cheap check first: an alphanumeric run followed by
+	// a connecting character and another alphanumeric (or a '/')
+	n := 0
+	for n < len(data) && isAlphanumeric(data[n]) {
+		n++
+	}
+	if n == 0 || n+1 >= len(data) || !strings.ContainsRune(".-_@:+", data[n]) {
+		return 0
+	}
+	if !isAlphanumeric(data[n+1]) && data[n+1] != '/' {
+		return 0
+	}
+
+	end := n
+	for end < len(data) && end < maxCompoundLength && !unicode.IsSpace(data[end]) {
+		end++
+	}
+	text := string(data[:end])
+
+	longest := ""
+	for _, pattern := range compoundPatterns {
+		match := strings.TrimRight(pattern.FindString(text), ".,;:!?'\")]")
+		if len(match) > len(longest) && isCompound(match) {
+			longest = match
+		}
+	}
+	return utf8.RuneCountInString(longest)
+}
+
+// isCompound rejects hyphenated words like well-known,
+// only codes and identifiers with digits or underscores are kept whole.
+func isCompound(token string) bool {
+	if strings.ContainsAny(token, ".@:") {
+		return true
+	}
+	return strings.ContainsRune(token, '_') || strings.IndexFunc(token, unicode.IsDigit) >= 0
+}
+
+// compoundParts returns the runs of letters and digits of a compound token,
+// so e.g. "example" also finds user@example.com.
+func compoundParts(token []rune) []string {
+	parts := make([]string, 0)
+	tokenizer := &Tokenizer{data: token}
+	for len(tokenizer.data) > 0 {
+		var part []rune
+		switch r := tokenizer.data[0]; {
+		case unicode.IsDigit(r):
+			part = tokenizer.removeIf(unicode.IsDigit)
+		case unicode.IsLetter(r):
+			part = tokenizer.removeIf(isAlphanumeric)
+		default:
+			tokenizer.removeFirst(1)
+			continue
+		}
+		parts = append(parts, string(part))
+	}
+	return parts
+}
+
+func isAlphanumeric(r rune) bool {
+	return (unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)) && !isCJK(r)
+}
+
+// isWord reports whether the token is a run of letters and digits starting
+// with a letter, which are the only tokens stemmed.
+func isWord(token string) bool {
+	return startsWithLetter(token) && strings.IndexFunc(token, func(r rune) bool {
+		return !isAlphanumeric(r)
+	}) < 0
+}
@@ -0,0 +1,139 @@
+package engine
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/gfxv/scout/internal/models"
+)
+
+// maxWildcardExpansions limits how many indexed terms a wildcard pattern
+// expands to, the ones found in most documents are kept.
+const maxWildcardExpansions = 50
+
+// termDictionary is the sorted list of indexed terms, built by Load.
+type termDictionary []string
+
+func newTermDictionary(docFreq models.TermFreq) termDictionary {
+	terms := make(termDictionary, 0, len(docFreq))
+	for term := range docFreq {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+	return terms
+}
+
+// withPrefix returns the terms starting with prefix, all of them for "".
+func (d termDictionary) withPrefix(prefix string) []string {
+	start := sort.SearchStrings(d, prefix)
+	end := start
+	for end < len(d) && strings.HasPrefix(d[end], prefix) {
+		end++
+	}
+	return d[start:end]
+}
+
+// surfaceDictionary is the sorted list of the words indexed terms were
+// analyzed from, the term itself for terms without a known one.
+type surfaceDictionary struct {
+	words termDictionary
+	// terms maps the words to their terms
+	terms map[string]string
+}
+
+func newSurfaceDictionary(terms termDictionary, surfaces map[string]string) surfaceDictionary {
+	dictionary := surfaceDictionary{
+		words: make(termDictionary, 0, len(terms)),
+		terms: make(map[string]string, len(terms)),
+	}
+	for _, term := range terms {
+		word, ok := surfaces[term]
+		if !ok {
+			word = term
+		}
+		if _, seen := dictionary.terms[word]; !seen {
+			dictionary.words = append(dictionary.words, word)
+		}
+		dictionary.terms[word] = term
+	}
+	sort.Strings(dictionary.words)
+	return dictionary
+}
+
+// surfaceOf returns the word the term was indexed from, the term itself if unknown.
+func (i *Indexer) surfaceOf(term string) string {
+	if word, ok := i.surfaces[term]; ok {
+		return word
+	}
+	return term
+}
+
+// isWildcardPattern reports whether the query word is a pattern like kube* or f?o.
+// A trailing ? is a question mark, and a pattern needs at least one letter or digit.
+func isWildcardPattern(word string) bool {
+	word = strings.TrimRight(word, "?")
+	if !strings.ContainsAny(word, "*?") {
+		return false
+	}
+	return strings.IndexFunc(word, func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	}) >= 0
+}
+
+// wildcardMatches returns the terms of the scope matching the pattern
+// normalized by the analyzer, where * matches any number of characters
+// and ? exactly one.
+func (i *Indexer) wildcardMatches(pattern string, scope searchScope, analyzer *Analyzer) []string {
+	pattern = analyzer.normalizePattern(pattern)
+	prefix := pattern
+	if n := strings.IndexAny(pattern, "*?"); n >= 0 {
+		prefix = pattern[:n]
+	}
+
+	patternRunes := []rune(pattern)
+	matches := make([]string, 0)
+	for _, term := range i.terms.withPrefix(prefix) {
+		if matchWildcard(patternRunes, []rune(term)) && scope.docFrequency(term) > 0 {
+			matches = append(matches, term)
+		}
+	}
+
+	if len(matches) > maxWildcardExpansions {
+		sort.Slice(matches, func(a, b int) bool {
+			freqA, freqB := scope.docFrequency(matches[a]), scope.docFrequency(matches[b])
+			if freqA != freqB {
+				return freqA > freqB
+			}
+			return matches[a] < matches[b]
+		})
+		matches = matches[:maxWildcardExpansions]
+	}
+	return matches
+}
+
+func matchWildcard(pattern, term []rune) bool {
+	p, t := 0, 0
+	// position of the last * and the term position it was tried at,
+	// to backtrack to when the rest of the pattern doesn't match
+	star, starT := -1, 0
+	for t < len(term) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == term[t]):
+			p++
+			t++
+		case p < len(pattern) && pattern[p] == '*':
+			star, starT = p, t
+			p++
+		case star >= 0:
+			starT++
+			p, t = star+1, starT
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
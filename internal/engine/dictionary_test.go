@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gfxv/scout/internal/models"
+)
+
+func TestMatchWildcard(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		term     string
+		expected bool
+	}{
+		{pattern: "kube*", term: "kubernet", expected: true},
+		{pattern: "kube*", term: "kube", expected: true},
+		{pattern: "kube*", term: "kub", expected: false},
+		{pattern: "f?o", term: "foo", expected: true},
+		{pattern: "f?o", term: "fo", expected: false},
+		{pattern: "f?o", term: "fooo", expected: false},
+		{pattern: "*ops", term: "devops", expected: true},
+		{pattern: "a*b*c", term: "axxbyybc", expected: true},
+		{pattern: "a*b*c", term: "axxbyyb", expected: false},
+		{pattern: "?ö*", term: "föhn", expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+"/"+tt.term, func(t *testing.T) {
+			if got := matchWildcard([]rune(tt.pattern), []rune(tt.term)); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestTermDictionary_withPrefix(t *testing.T) {
+	dict := newTermDictionary(models.TermFreq{"kube": 1, "kubelet": 1, "kubernet": 2, "kubb": 1, "kuc": 1})
+
+	expected := []string{"kube", "kubelet", "kubernet"}
+	if got := dict.withPrefix("kube"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+	if got := dict.withPrefix("zzz"); len(got) != 0 {
+		t.Errorf("expected no terms, got %q", got)
+	}
+}
+
+func TestIndexer_SearchWildcard(t *testing.T) {
+	files := map[string]string{
+		"kube.md":  "kubernetes cluster",
+		"let.md":   "kubelet logs",
+		"food.md":  "foo bar",
+		"other.md": "lunch menu",
+	}
+	for n := 0; n < 2*maxWildcardExpansions; n++ {
+		files[fmt.Sprintf("x%d.md", n)] = fmt.Sprintf("xterm%03d", n)
+	}
+	indexer, _ := newMemoryIndexer(t, files)
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []string
+	}{
+		{name: "prefix", query: "kube*", expected: []string{"kube.md", "let.md"}},
+		{name: "uppercase", query: "Kube*", expected: []string{"kube.md", "let.md"}},
+		{name: "full-width", query: "ＫＵＢＥ＊", expected: []string{"kube.md", "let.md"}},
+		{name: "single character", query: "f?o", expected: []string{"food.md"}},
+		{name: "no match", query: "zzz*", expected: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]string, 0)
+			for _, result := range indexer.SearchQuery(tt.query) {
+				if result.Rank() > 0 {
+					got = append(got, filepath.Base(result.Path()))
+				}
+			}
+			if !sameElements(got, tt.expected) {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+
+	scope := indexer.newSearchScope(nil)
+	if got := indexer.wildcardMatches("xterm*", scope, indexer.analyzer); len(got) != maxWildcardExpansions {
+		t.Errorf("expected expansion to be limited to %d terms, got %d", maxWildcardExpansions, len(got))
+	}
+}
+
+func sameElements(a, b []string) bool {
+	counts := make(map[string]int)
+	for _, s := range a {
+		counts[s]++
+	}
+	for _, s := range b {
+		counts[s]--
+	}
+	for _, count := range counts {
+		if count != 0 {
+			return false
+		}
+	}
+	return true
+}
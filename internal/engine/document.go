@@ -0,0 +1,234 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/gfxv/scout/internal/models"
+)
+
+// maxViewRunes limits how much of a document's text DocumentView returns.
+const maxViewRunes = 1 << 20
+
+var (
+	ErrDocumentNotFound = errors.New("document not found")
+	// ErrOutsideRoots is returned for documents that (after resolving
+	// symlinks) are no longer inside any indexed directory.
+	ErrOutsideRoots = errors.New("document is outside of the indexed directories")
+)
+
+// Document returns the path and index entry of the document with the given ID.
+func (i *Indexer) Document(id int) (string, models.DocInfo, error) {
+	i.diMu.Lock()
+	defer i.diMu.Unlock()
+
+	path, ok := i.docPaths[id]
+	if !ok {
+		return "", models.DocInfo{}, fmt.Errorf("%w: %d", ErrDocumentNotFound, id)
+	}
+	return path, i.documentIndex[path], nil
+}
+
+// DocumentView returns the text of the document with the words
+// matching the query highlighted, the query is analyzed and expanded the
+// same way as when searching. The view of a passage shows its whole file,
+// split into passages again.
+func (i *Indexer) DocumentView(id int, rawQuery string) (models.DocumentView, error) {
+	path, docInfo, err := i.Document(id)
+	if err != nil {
+		return models.DocumentView{}, err
+	}
+	content, _, err := i.documentText(path, docInfo)
+	if err != nil {
+		return models.DocumentView{}, err
+	}
+
+	file := docInfo.FilePath(path)
+	view := models.DocumentView{
+		ID:         id,
+		Path:       file,
+		Collection: docInfo.Collection,
+		Anchor:     docInfo.Anchor(path),
+		Page:       docInfo.Page,
+	}
+	passages := []passage{{text: content}}
+	if docInfo.Parent != "" {
+		if split := i.splitPassages(file, content); len(split) > 0 {
+			passages = split
+		}
+	}
+
+	analyzer := i.analyzerFor(docInfo.Collection)
+	expansion := i.expandTerms(ParseQuery(rawQuery), i.newSearchScope([]string{docInfo.Collection}), analyzer)
+	terms := make(map[string]bool, len(expansion.terms))
+	for _, term := range expansion.terms {
+		terms[term.term] = true
+	}
+
+	remaining := maxViewRunes
+	for _, passage := range passages {
+		if remaining <= 0 {
+			view.Truncated = true
+			break
+		}
+		text := passage.text
+		if len(text) > remaining {
+			text = text[:remaining]
+			view.Truncated = true
+		}
+		remaining -= len(text)
+		view.Passages = append(view.Passages, models.PassageView{
+			Anchor:   passage.anchor,
+			Label:    passage.label,
+			Page:     passage.page,
+			Segments: highlight(text, analyzer, terms),
+		})
+	}
+	return view, nil
+}
+
+// DocumentFile returns the path of the original file of the document,
+// making sure it is inside one of the directories collections were indexed from. For files in
+// archives it's a virtual path, see OpenDocumentFile.
+func (i *Indexer) DocumentFile(id int) (string, error) {
+	path, docInfo, err := i.Document(id)
+	if err != nil {
+		return "", err
+	}
+	path = docInfo.FilePath(path)
+	file, entry, inArchive := splitArchivePath(path)
+	if !inArchive {
+		file = path
+	}
+	resolved, err := resolvePath(file)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrDocumentNotFound, err)
+	}
+
+	collections, err := i.store.GetCollections()
+	if err != nil {
+		return "", fmt.Errorf("failed to load collections, err: %w", err)
+	}
+	for _, collection := range collections {
+		for _, root := range collection.Roots {
+			root, err := resolvePath(root)
+			if err != nil || !isWithin(resolved, root) {
+				continue
+			}
+			if inArchive {
+				return resolved + archiveSeparator + entry, nil
+			}
+			return resolved, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrOutsideRoots, path)
+}
+
+// OpenDocumentFile opens the original file of the document, see DocumentFile,
+// and returns it along with its path. Files in archives are extracted first.
+func (i *Indexer) OpenDocumentFile(id int) (io.ReadCloser, string, error) {
+	path, err := i.DocumentFile(id)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, _, ok := splitArchivePath(path); !ok {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, "", err
+		}
+		return file, path, nil
+	}
+
+	local, err := extractVirtual(path, i.archiveEntryLimit())
+	if err != nil {
+		return nil, "", err
+	}
+	file, err := os.Open(local)
+	if err != nil {
+		os.Remove(local)
+		return nil, "", err
+	}
+	return tempFile{file}, path, nil
+}
+
+// resolvePath returns the absolute path with symlinks resolved.
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
+
+func isWithin(path, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// highlight splits the text into segments, highlighting the words (without
+// surrounding punctuation) the analyzer turns into any of the terms.
+func highlight(text []rune, analyzer *Analyzer, terms map[string]bool) []models.TextSegment {
+	segments := make([]models.TextSegment, 0)
+	plain := make([]rune, 0)
+	matches := make(map[string]bool)
+
+	flush := func() {
+		if len(plain) > 0 {
+			segments = append(segments, models.TextSegment{Text: string(plain)})
+			plain = plain[:0]
+		}
+	}
+
+	for start := 0; start < len(text); {
+		end := start
+		for end < len(text) && !unicode.IsSpace(text[end]) {
+			end++
+		}
+		if end == start {
+			plain = append(plain, text[start])
+			start++
+			continue
+		}
+
+		// trim surrounding punctuation, e.g. "(kubernetes),"
+		wordStart, wordEnd := start, end
+		for wordStart < wordEnd && !isAlphanumeric(text[wordStart]) {
+			wordStart++
+		}
+		for wordEnd > wordStart && !isAlphanumeric(text[wordEnd-1]) {
+			wordEnd--
+		}
+
+		word := string(text[wordStart:wordEnd])
+		matched, ok := matches[word]
+		if !ok && word != "" {
+			for _, term := range analyzer.Analyze([]rune(word)) {
+				if terms[term] {
+					matched = true
+					break
+				}
+			}
+			matches[word] = matched
+		}
+
+		if !matched {
+			plain = append(plain, text[start:end]...)
+		} else {
+			plain = append(plain, text[start:wordStart]...)
+			flush()
+			segments = append(segments, models.TextSegment{Text: word, Highlight: true})
+			plain = append(plain, text[wordEnd:end]...)
+		}
+		start = end
+	}
+	flush()
+	return segments
+}
@@ -0,0 +1,148 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gfxv/scout/internal/models"
+)
+
+func TestHighlight(t *testing.T) {
+	analyzer, err := NewAnalyzer(AnalyzerConfig{}, "english")
+	if err != nil {
+		t.Fatal(err)
+	}
+	terms := map[string]bool{"deploy": true, "kubernet": true}
+
+	tests := []struct {
+		name     string
+		text     string
+		expected []models.TextSegment
+	}{
+		{
+			name: "stemmed word with punctuation",
+			text: "Deploying (Kubernetes), then rest",
+			expected: []models.TextSegment{
+				{Text: "Deploying", Highlight: true},
+				{Text: " ("},
+				{Text: "Kubernetes", Highlight: true},
+				{Text: "), then rest"},
+			},
+		},
+		{
+			name:     "no match",
+			text:     "nothing\n  here",
+			expected: []models.TextSegment{{Text: "nothing\n  here"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			segments := highlight([]rune(test.text), analyzer, terms)
+			if len(segments) != len(test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, segments)
+			}
+			for n := range segments {
+				if segments[n] != test.expected[n] {
+					t.Errorf("expected %v, got %v", test.expected[n], segments[n])
+				}
+			}
+		})
+	}
+}
+
+func TestIndexer_DocumentView(t *testing.T) {
+	indexer, dir := newMemoryIndexer(t, map[string]string{
+		"deploy.md": "How to deploy the service",
+	})
+
+	results := indexer.SearchQuery("deploying")
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(results))
+	}
+	view, err := indexer.DocumentView(results[0].ID(), "deploying")
+	if err != nil {
+		t.Fatalf("DocumentView failed: %v", err)
+	}
+	if view.Path != filepath.Join(dir, "deploy.md") {
+		t.Errorf("expected path %q, got %q", filepath.Join(dir, "deploy.md"), view.Path)
+	}
+	if len(view.Passages) != 1 {
+		t.Fatalf("expected 1 passage, got %d", len(view.Passages))
+	}
+	highlighted := make([]string, 0)
+	for _, segment := range view.Passages[0].Segments {
+		if segment.Highlight {
+			highlighted = append(highlighted, segment.Text)
+		}
+	}
+	if len(highlighted) != 1 || highlighted[0] != "deploy" {
+		t.Errorf("expected [deploy] highlighted, got %v", highlighted)
+	}
+
+	if _, err := indexer.DocumentView(-1, ""); !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("expected ErrDocumentNotFound, got %v", err)
+	}
+}
+
+func TestIndexer_DocumentFile(t *testing.T) {
+	outside := writeFiles(t, map[string]string{"secret.md": "secret notes"})
+	indexer, dir := newMemoryIndexer(t, map[string]string{
+		"notes.md": "meeting notes",
+		"link.md":  "meeting notes",
+	})
+	results := make(map[string]int)
+	for _, result := range indexer.SearchQuery("notes") {
+		results[filepath.Base(result.Path())] = result.ID()
+	}
+
+	path, err := indexer.DocumentFile(results["notes.md"])
+	if err != nil {
+		t.Fatalf("DocumentFile failed: %v", err)
+	}
+	if want, _ := filepath.EvalSymlinks(filepath.Join(dir, "notes.md")); path != want {
+		t.Errorf("expected %q, got %q", want, path)
+	}
+
+	// the file is swapped for a symlink out of the indexed directory after indexing
+	link := filepath.Join(dir, "link.md")
+	if err := os.Remove(link); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret.md"), link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	if _, err := indexer.DocumentFile(results["link.md"]); !errors.Is(err, ErrOutsideRoots) {
+		t.Errorf("expected ErrOutsideRoots, got %v", err)
+	}
+}
+
+func TestIndexer_DocumentFileSources(t *testing.T) {
+	specs := writeFiles(t, map[string]string{"a.md": "deploy specs"})
+	runbooks := writeFiles(t, map[string]string{"b.md": "deploy runbooks"})
+	indexer, err := NewIndexerWithStore(newTestStore())
+	if err != nil {
+		t.Fatalf("NewIndexerWithStore failed: %v", err)
+	}
+	// two sources feeding the same collection
+	for _, root := range []string{specs, runbooks} {
+		if err := indexer.IndexSource(Source{Collection: "docs", Root: root}); err != nil {
+			t.Fatalf("IndexSource failed: %v", err)
+		}
+	}
+	if err := indexer.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	results := indexer.SearchQuery("deploy")
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	for _, result := range results {
+		if _, err := indexer.DocumentFile(result.ID()); err != nil {
+			t.Errorf("DocumentFile of %s failed: %v", result.Path(), err)
+		}
+	}
+}
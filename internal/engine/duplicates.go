@@ -0,0 +1,239 @@
+package engine
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/gfxv/scout/internal/models"
+)
+
+const (
+	// shingleSize is the number of consecutive words hashed together by simHash.
+	shingleSize = 3
+	// nearDuplicateDistance is the max number of differing SimHash bits
+	// of two documents considered near duplicates.
+	nearDuplicateDistance = 3
+	// simHashBands is the number of bands signatures are split into for
+	// finding near duplicates, one more than nearDuplicateDistance, so
+	// near duplicates have at least one band in common.
+	simHashBands = nearDuplicateDistance + 1
+)
+
+// simHash returns a 64-bit signature of the text, texts differing in a few
+// words get signatures differing in a few bits. It's computed over lowercase
+// word shingles, so it doesn't depend on the analyzer.
+// See https://en.wikipedia.org/wiki/SimHash.
+func simHash(text []rune) uint64 {
+	words := strings.FieldsFunc(strings.ToLower(string(text)), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return 0
+	}
+
+	var weights [64]int
+	for start := 0; start+shingleSize <= max(len(words), shingleSize); start++ {
+		end := min(start+shingleSize, len(words))
+		hash := fnv.New64a()
+		hash.Write([]byte(strings.Join(words[start:end], " ")))
+		sum := hash.Sum64()
+		for bit := range weights {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var signature uint64
+	for bit, weight := range weights {
+		if weight > 0 {
+			signature |= 1 << bit
+		}
+	}
+	return signature
+}
+
+// DuplicateCluster is a group of documents with the same or nearly the same text.
+type DuplicateCluster struct {
+	// Paths are sorted
+	Paths []string
+	// Exact is set if all documents have the same content
+	Exact bool
+}
+
+// Duplicates returns the clusters of duplicate documents, exact ones first.
+// Documents indexed before signatures existed are only matched by content.
+func (i *Indexer) Duplicates() []DuplicateCluster {
+	i.diMu.Lock()
+	defer i.diMu.Unlock()
+
+	files := fileIndex(i.documentIndex)
+	clusters := make([]DuplicateCluster, 0)
+	for _, paths := range duplicateGroups(files) {
+		if len(paths) < 2 {
+			continue
+		}
+		sort.Strings(paths)
+		exact := true
+		for _, path := range paths[1:] {
+			hash := files[path].ContentHash
+			exact = exact && hash != "" && hash == files[paths[0]].ContentHash
+		}
+		clusters = append(clusters, DuplicateCluster{Paths: paths, Exact: exact})
+	}
+	sort.Slice(clusters, func(a, b int) bool {
+		if clusters[a].Exact != clusters[b].Exact {
+			return clusters[a].Exact
+		}
+		return clusters[a].Paths[0] < clusters[b].Paths[0]
+	})
+	return clusters
+}
+
+// fileIndex returns the index entry of every file by its path, passages
+// share their file's content hash and signature, so any of them will do.
+func fileIndex(docIndex models.DocIndex) models.DocIndex {
+	files := make(models.DocIndex, len(docIndex))
+	for path, docInfo := range docIndex {
+		files[docInfo.FilePath(path)] = docInfo
+	}
+	return files
+}
+
+// duplicateGroups groups the paths of files with the same content hash
+// or SimHash signatures at most nearDuplicateDistance bits apart, including
+// files without duplicates as groups of one.
+func duplicateGroups(docIndex models.DocIndex) [][]string {
+	paths := make([]string, 0, len(docIndex))
+	for path := range docIndex {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	// union-find over document positions
+	parent := make([]int, len(paths))
+	for n := range parent {
+		parent[n] = n
+	}
+	var find func(n int) int
+	find = func(n int) int {
+		if parent[n] != n {
+			parent[n] = find(parent[n])
+		}
+		return parent[n]
+	}
+	union := func(a, b int) {
+		parent[find(a)] = find(b)
+	}
+
+	byHash := make(map[string]int)
+	for n, path := range paths {
+		hash := docIndex[path].ContentHash
+		if hash == "" {
+			continue
+		}
+		if first, ok := byHash[hash]; ok {
+			union(n, first)
+		} else {
+			byHash[hash] = n
+		}
+	}
+	// documents with equal signatures are compared once
+	bySignature := make(map[uint64]int)
+	signatures := make([]uint64, 0)
+	for n, path := range paths {
+		signature := docIndex[path].SimHash
+		if signature == 0 {
+			continue
+		}
+		if first, ok := bySignature[signature]; ok {
+			union(n, first)
+			continue
+		}
+		bySignature[signature] = n
+		signatures = append(signatures, signature)
+	}
+	for _, bucket := range simHashBuckets(signatures) {
+		for a := range bucket {
+			for b := a + 1; b < len(bucket); b++ {
+				if bits.OnesCount64(bucket[a]^bucket[b]) <= nearDuplicateDistance {
+					union(bySignature[bucket[a]], bySignature[bucket[b]])
+				}
+			}
+		}
+	}
+
+	groups := make(map[int][]string)
+	for n, path := range paths {
+		root := find(n)
+		groups[root] = append(groups[root], path)
+	}
+	result := make([][]string, 0, len(groups))
+	for _, group := range groups {
+		result = append(result, group)
+	}
+	return result
+}
+
+// simHashBuckets groups the signatures sharing the value of a band, only
+// signatures in the same bucket can be near duplicates. Buckets of one
+// signature are left out.
+func simHashBuckets(signatures []uint64) [][]uint64 {
+	const width = 64 / simHashBands
+	buckets := make(map[[2]uint64][]uint64)
+	for _, signature := range signatures {
+		for band := uint64(0); band < simHashBands; band++ {
+			value := (signature >> (band * width)) & (1<<width - 1)
+			key := [2]uint64{band, value}
+			buckets[key] = append(buckets[key], signature)
+		}
+	}
+	result := make([][]uint64, 0)
+	for _, bucket := range buckets {
+		if len(bucket) > 1 {
+			result = append(result, bucket)
+		}
+	}
+	return result
+}
+
+// duplicateOf maps every file with duplicates to the first path of its group.
+func duplicateOf(docIndex models.DocIndex) map[string]string {
+	result := make(map[string]string)
+	for _, group := range duplicateGroups(fileIndex(docIndex)) {
+		if len(group) < 2 {
+			continue
+		}
+		sort.Strings(group)
+		for _, path := range group {
+			result[path] = group[0]
+		}
+	}
+	return result
+}
+
+// collapseDuplicates keeps the best ranked result of every group of
+// duplicates, counting the others on it. The results must be sorted.
+func (i *Indexer) collapseDuplicates(results []models.SearchQueryResult) []models.SearchQueryResult {
+	kept := make([]models.SearchQueryResult, 0, len(results))
+	position := make(map[string]int)
+	for _, result := range results {
+		group, ok := i.duplicates[result.Path()]
+		if !ok {
+			kept = append(kept, result)
+			continue
+		}
+		if n, seen := position[group]; seen {
+			kept[n].AddDuplicate()
+			continue
+		}
+		position[group] = len(kept)
+		kept = append(kept, result)
+	}
+	return kept
+}
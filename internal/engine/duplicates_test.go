@@ -0,0 +1,126 @@
+package engine
+
+import (
+	"math/bits"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gfxv/scout/internal/models"
+)
+
+func TestSimHash(t *testing.T) {
+	text := longText(2000, 1)
+	edited := editWords(text, 10, 500, 1500)
+	other := longText(2000, 7)
+
+	if simHash([]rune(text)) != simHash([]rune(strings.ToUpper(text))) {
+		t.Error("expected the signature to ignore case")
+	}
+	if d := bits.OnesCount64(simHash([]rune(text)) ^ simHash([]rune(edited))); d > nearDuplicateDistance {
+		t.Errorf("expected an edited copy within %d bits, got %d", nearDuplicateDistance, d)
+	}
+	if d := bits.OnesCount64(simHash([]rune(text)) ^ simHash([]rune(other))); d <= nearDuplicateDistance {
+		t.Errorf("expected unrelated texts more than %d bits apart, got %d", nearDuplicateDistance, d)
+	}
+	if simHash([]rune(" ,. ")) != 0 {
+		t.Error("expected 0 for text without words")
+	}
+}
+
+func TestDuplicateGroups_NearSignatures(t *testing.T) {
+	const base = uint64(0x9e3779b97f4a7c15)
+	docIndex := models.DocIndex{
+		"a.md": {SimHash: base},
+		// 3 bits apart, in 3 different bands
+		"b.md": {SimHash: base ^ (1<<0 | 1<<20 | 1<<40)},
+		// 4 bits apart from a.md, one in every band
+		"c.md": {SimHash: base ^ (1<<1 | 1<<17 | 1<<33 | 1<<49)},
+		"d.md": {SimHash: base ^ (1<<1 | 1<<17 | 1<<33 | 1<<49)},
+		"e.md": {},
+	}
+
+	groups := make([]string, 0)
+	for _, group := range duplicateGroups(docIndex) {
+		sort.Strings(group)
+		groups = append(groups, strings.Join(group, ","))
+	}
+	sort.Strings(groups)
+	if got := strings.Join(groups, " "); got != "a.md,b.md c.md,d.md e.md" {
+		t.Errorf("expected a.md,b.md c.md,d.md e.md, got %s", got)
+	}
+}
+
+func TestIndexer_Duplicates(t *testing.T) {
+	runbook := longText(2000, 1) + " uncordon"
+	notes := longText(2000, 7)
+	files := map[string]string{
+		"upgrade.md":      runbook,
+		"copy/upgrade.md": runbook,
+		"upgrade-v2.md":   editWords(runbook, 10, 500),
+		"notes.md":        notes,
+		"notes-copy.md":   notes,
+		"other.txt":       longText(2000, 13),
+	}
+	dir := writeFiles(t, files)
+	opts := DefaultOptions()
+	opts.CollapseDuplicates = true
+	indexer, err := NewIndexerWithOptions(newTestStore(), opts)
+	if err != nil {
+		t.Fatalf("NewIndexerWithOptions failed: %v", err)
+	}
+	if err := indexer.IndexDir(models.DefaultCollection, dir); err != nil {
+		t.Fatalf("IndexDir failed: %v", err)
+	}
+	if err := indexer.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	clusters := indexer.Duplicates()
+	if len(clusters) != 2 {
+		t.Fatalf("expected 2 clusters, got %+v", clusters)
+	}
+	if !clusters[0].Exact || len(clusters[0].Paths) != 2 || filepath.Base(clusters[0].Paths[0]) != "notes-copy.md" {
+		t.Errorf("expected the exact copies of the notes first, got %+v", clusters[0])
+	}
+	if clusters[1].Exact || len(clusters[1].Paths) != 3 {
+		t.Errorf("expected 3 near duplicate runbooks, got %+v", clusters[1])
+	}
+
+	results := indexer.SearchQuery("uncordon")
+	matching := make([]models.SearchQueryResult, 0)
+	for _, result := range results {
+		if result.Rank() > 0 {
+			matching = append(matching, result)
+		}
+	}
+	if len(matching) != 1 {
+		t.Fatalf("expected the runbooks collapsed into 1 result, got %d", len(matching))
+	}
+	if matching[0].Duplicates() != 2 {
+		t.Errorf("expected 2 collapsed duplicates, got %d", matching[0].Duplicates())
+	}
+}
+
+// longText returns a text of n words, different seeds give different texts.
+func longText(n, seed int) string {
+	vocabulary := strings.Fields("node cluster pod upgrade drain kubelet restart check version network storage " +
+		"volume backup restore service deploy rollout config secret health metrics log alert disk memory")
+	words := make([]string, n)
+	state := seed
+	for i := range words {
+		state = (state*1103515245 + 12345) % (1 << 31)
+		words[i] = vocabulary[state%len(vocabulary)]
+	}
+	return strings.Join(words, " ")
+}
+
+// editWords replaces the words at the given positions.
+func editWords(text string, positions ...int) string {
+	words := strings.Fields(text)
+	for _, n := range positions {
+		words[n] = "edited"
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,142 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const (
+	// pathPlaceholder is replaced by the file's path in external commands
+	pathPlaceholder = "{path}"
+	// defaultCommandTimeout limits how long an external extractor may run
+	defaultCommandTimeout = 30 * time.Second
+	// defaultCommandMaxOutput limits how much text an external extractor may print
+	defaultCommandMaxOutput = 64 << 20
+	// maxCommandStderr is how much of an extractor's stderr is kept for errors
+	maxCommandStderr = 4 << 10
+)
+
+var errOutputTooLarge = errors.New("output too large")
+
+// validateCommand checks that the external extractor command gets the
+// file's path. Whether it's installed is checked by lookCommand.
+func validateCommand(command []string) error {
+	if len(command) == 0 {
+		return fmt.Errorf("command is empty")
+	}
+	for _, arg := range command[1:] {
+		if strings.Contains(arg, pathPlaceholder) {
+			return nil
+		}
+	}
+	return fmt.Errorf("command %s has no %s argument", strings.Join(command, " "), pathPlaceholder)
+}
+
+// lookCommand checks that the external extractor command is installed.
+func lookCommand(command []string) error {
+	if _, err := exec.LookPath(command[0]); err != nil {
+		return fmt.Errorf("command %s not found, err: %w", command[0], err)
+	}
+	return nil
+}
+
+// commandRunner runs external extractors, at most one per CPU at a time,
+// each limited in run time and output size.
+type commandRunner struct {
+	timeout   time.Duration
+	maxOutput int64
+	slots     chan struct{}
+}
+
+func newCommandRunner(timeout time.Duration, maxOutput int64) *commandRunner {
+	if timeout <= 0 {
+		timeout = defaultCommandTimeout
+	}
+	if maxOutput <= 0 {
+		maxOutput = defaultCommandMaxOutput
+	}
+	return &commandRunner{
+		timeout:   timeout,
+		maxOutput: maxOutput,
+		slots:     make(chan struct{}, runtime.NumCPU()),
+	}
+}
+
+// reader returns a reader running the command.
+func (r *commandRunner) reader(command []string) readerFunc {
+	return func(path string) ([]rune, error) {
+		return r.run(command, path)
+	}
+}
+
+// run runs the command with the path in place of pathPlaceholder and returns
+// what it printed to stdout as the file's text. The command is killed if it
+// runs too long or prints too much.
+func (r *commandRunner) run(command []string, path string) ([]rune, error) {
+	r.slots <- struct{}{}
+	defer func() { <-r.slots }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	args := make([]string, 0, len(command)-1)
+	for _, arg := range command[1:] {
+		args = append(args, strings.ReplaceAll(arg, pathPlaceholder, path))
+	}
+	cmd := exec.CommandContext(ctx, command[0], args...)
+	stdout := &limitedBuffer{limit: r.maxOutput, cancel: cancel}
+	stderr := &limitedBuffer{limit: maxCommandStderr}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// children of the command (e.g. of a shell) may keep its output open
+	// after it's killed, stop waiting for them
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	switch {
+	case stdout.overflow:
+		err = fmt.Errorf("%w, more than %d bytes", errOutputTooLarge, r.maxOutput)
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		err = fmt.Errorf("timed out after %v", r.timeout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to run %s on %s, err: %w, stderr: %s", command[0], path, err, strings.TrimSpace(stderr.String()))
+	}
+	return bytes.Runes(stdout.Bytes()), nil
+}
+
+// limitedBuffer keeps up to limit bytes written to it and drops the rest,
+// calling cancel (if set) once there's more. It doesn't embed bytes.Buffer,
+// whose ReadFrom would let io.Copy bypass the limit.
+type limitedBuffer struct {
+	buf      bytes.Buffer
+	limit    int64
+	overflow bool
+	cancel   func()
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - int64(b.buf.Len()); int64(len(p)) > room {
+		b.buf.Write(p[:max(room, 0)])
+		if !b.overflow && b.cancel != nil {
+			b.cancel()
+		}
+		b.overflow = true
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) Bytes() []byte {
+	return b.buf.Bytes()
+}
+
+func (b *limitedBuffer) String() string {
+	return b.buf.String()
+}
@@ -0,0 +1,153 @@
+package engine
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gfxv/scout/internal/models"
+)
+
+func TestCommandRunner(t *testing.T) {
+	tests := []struct {
+		name    string
+		command []string
+		want    string
+		err     string
+	}{
+		{name: "stdout", command: []string{"sh", "-c", "echo text of $0", "{path}"}, want: "text of doc.rtf\n"},
+		{name: "failure", command: []string{"sh", "-c", "echo broken >&2; exit 3", "{path}"}, err: "broken"},
+		{name: "timeout", command: []string{"sh", "-c", "sleep 5", "{path}"}, err: "timed out"},
+		{name: "output too large", command: []string{"sh", "-c", "head -c 2048 /dev/zero", "{path}"}, err: errOutputTooLarge.Error()},
+	}
+
+	runner := newCommandRunner(200*time.Millisecond, 1024)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, err := runner.run(tt.command, "doc.rtf")
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("expected error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("run failed: %v", err)
+			}
+			if string(text) != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, string(text))
+			}
+		})
+	}
+}
+
+func TestIndexer_Commands(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"letter.RTF": "{\\rtf1 dear customer}",
+		"notes.md":   "meeting notes",
+	})
+	opts := DefaultOptions()
+	opts.Commands = map[string][]string{
+		".rtf": {"sed", "s/[{}]//g; s/\\\\rtf1 //", "{path}"},
+		// takes precedence over the text reader
+		".md": {"sed", "s/meeting/standup/", "{path}"},
+	}
+	indexer, err := NewIndexerWithOptions(newTestStore(), opts)
+	if err != nil {
+		t.Fatalf("NewIndexerWithOptions failed: %v", err)
+	}
+	if err := indexer.IndexDir(models.DefaultCollection, dir); err != nil {
+		t.Fatalf("IndexDir failed: %v", err)
+	}
+	if err := indexer.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	results := indexer.SearchQuery("customer")
+	if len(results) == 0 || results[0].Rank() <= 0 || results[0].Path() != filepath.Join(dir, "letter.RTF") {
+		t.Fatalf("expected letter.RTF to match, got %v", results)
+	}
+	if _, ok := indexer.documentIndex[filepath.Join(dir, "letter.RTF")].Terms["rtf1"]; ok {
+		t.Error("expected the command's output to be indexed, not the file")
+	}
+	view, err := indexer.DocumentView(results[0].ID(), "")
+	if err != nil {
+		t.Fatalf("DocumentView failed: %v", err)
+	}
+	if text := view.Passages[0].Segments[0].Text; text != "dear customer" {
+		t.Errorf("expected the command's output in the view, got %q", text)
+	}
+	if results := indexer.SearchQuery("standup"); results[0].Rank() <= 0 || results[0].Path() != filepath.Join(dir, "notes.md") {
+		t.Errorf("expected notes.md read by its command, got %s", results[0].Path())
+	}
+}
+
+func TestOptions_Commands(t *testing.T) {
+	tests := []struct {
+		name     string
+		commands map[string][]string
+		valid    bool
+	}{
+		{name: "valid", commands: map[string][]string{".rtf": {"cat", "{path}"}}, valid: true},
+		{name: "no dot", commands: map[string][]string{"rtf": {"cat", "{path}"}}, valid: false},
+		{name: "no path", commands: map[string][]string{".rtf": {"cat"}}, valid: false},
+		{name: "empty", commands: map[string][]string{".rtf": {}}, valid: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.Commands = tt.commands
+			if err := opts.Validate(); (err == nil) != tt.valid {
+				t.Errorf("expected valid %v, got %v", tt.valid, err)
+			}
+		})
+	}
+}
+
+func TestIndexer_MissingCommands(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"letter.rtf": "{\\rtf1 dear customer}",
+		"notes.md":   "meeting notes",
+	})
+	opts := DefaultOptions()
+	opts.Commands = map[string][]string{
+		".rtf": {"scout-missing-extractor", "{path}"},
+		".md":  {"cat", "{path}"},
+	}
+	opts.PDFFallback = []string{"scout-missing-extractor", "{path}"}
+	if err := opts.CheckCommands(); err == nil || !strings.Contains(err.Error(), ".rtf") || !strings.Contains(err.Error(), "PDF fallback") {
+		t.Errorf("expected the .rtf command and the PDF fallback to be missing, got %v", err)
+	}
+
+	// searching doesn't run the commands, so they needn't be installed
+	indexer, err := NewIndexerWithOptions(newTestStore(), opts)
+	if err != nil {
+		t.Fatalf("NewIndexerWithOptions failed: %v", err)
+	}
+	if err := indexer.IndexDir(models.DefaultCollection, dir); err != nil {
+		t.Fatalf("IndexDir failed: %v", err)
+	}
+	if err := indexer.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if _, ok := indexer.documentIndex[filepath.Join(dir, "notes.md")]; !ok || len(indexer.documentIndex) != 1 {
+		t.Errorf("expected only notes.md to be indexed, got %d documents", len(indexer.documentIndex))
+	}
+	if len(indexer.opts.PDFFallback) > 0 {
+		t.Error("expected the missing PDF fallback to be dropped")
+	}
+}
+
+func TestLimitedBuffer(t *testing.T) {
+	cancelled := false
+	buffer := &limitedBuffer{limit: 5, cancel: func() { cancelled = true }}
+	for _, part := range []string{"abc", "defg", "h"} {
+		if n, err := buffer.Write([]byte(part)); n != len(part) || err != nil {
+			t.Fatalf("expected %d bytes written, got %d, %v", len(part), n, err)
+		}
+	}
+	if buffer.String() != "abcde" || !buffer.overflow || !cancelled {
+		t.Errorf("expected abcde kept and the overflow cancelled, got %q", buffer.String())
+	}
+}
@@ -0,0 +1,180 @@
+package engine
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxFuzzyExpansions limits how many dictionary terms a misspelt query term expands to.
+const maxFuzzyExpansions = 3
+
+// bkTree indexes terms by edit distance, so terms close to a query
+// term can be found without comparing it against the whole dictionary.
+// See https://en.wikipedia.org/wiki/BK-tree.
+type bkTree struct {
+	root *bkNode
+}
+
+type bkNode struct {
+	term     string
+	children map[int]*bkNode
+}
+
+type fuzzyMatch struct {
+	term     string
+	distance int
+}
+
+func newBKTree(terms []string) *bkTree {
+	tree := &bkTree{}
+	for _, term := range terms {
+		tree.add(term)
+	}
+	return tree
+}
+
+func (t *bkTree) add(term string) {
+	if t.root == nil {
+		t.root = &bkNode{term: term}
+		return
+	}
+	node := t.root
+	for {
+		distance := levenshtein(term, node.term)
+		if distance == 0 {
+			return
+		}
+		child, ok := node.children[distance]
+		if !ok {
+			if node.children == nil {
+				node.children = make(map[int]*bkNode)
+			}
+			node.children[distance] = &bkNode{term: term}
+			return
+		}
+		node = child
+	}
+}
+
+// search returns all terms within maxDistance edits of term.
+func (t *bkTree) search(term string, maxDistance int) []fuzzyMatch {
+	matches := make([]fuzzyMatch, 0)
+	if t == nil || t.root == nil {
+		return matches
+	}
+
+	stack := []*bkNode{t.root}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		distance := levenshtein(term, node.term)
+		if distance <= maxDistance {
+			matches = append(matches, fuzzyMatch{term: node.term, distance: distance})
+		}
+		// by the triangle inequality only children in this range can match
+		for d := distance - maxDistance; d <= distance+maxDistance; d++ {
+			if child, ok := node.children[d]; ok {
+				stack = append(stack, child)
+			}
+		}
+	}
+	return matches
+}
+
+// levenshtein returns the number of single rune insertions,
+// deletions and substitutions needed to turn a into b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// maxEdits allows one typo in short terms and two in long ones,
+// very short terms are too ambiguous to correct.
+func maxEdits(term string) int {
+	switch n := utf8.RuneCountInString(term); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// fuzzyWeight is the weight of an expanded term relative to an exact hit.
+func fuzzyWeight(distance int) float32 {
+	return 1 / float32(1+distance)
+}
+
+// fuzzyMatches returns the closest terms of the scope for a term that isn't
+// in it, best first: fewer edits, then more documents containing the term.
+func (i *Indexer) fuzzyMatches(term string, scope searchScope) []fuzzyMatch {
+	edits := maxEdits(term)
+	if edits == 0 {
+		return nil
+	}
+
+	matches := make([]fuzzyMatch, 0)
+	for _, match := range i.termTree.search(term, edits) {
+		if scope.docFrequency(match.term) > 0 {
+			matches = append(matches, match)
+		}
+	}
+	sort.Slice(matches, func(a, b int) bool {
+		if matches[a].distance != matches[b].distance {
+			return matches[a].distance < matches[b].distance
+		}
+		freqA, freqB := scope.docFrequency(matches[a].term), scope.docFrequency(matches[b].term)
+		if freqA != freqB {
+			return freqA > freqB
+		}
+		return matches[a].term < matches[b].term
+	})
+	if len(matches) > maxFuzzyExpansions {
+		matches = matches[:maxFuzzyExpansions]
+	}
+	return matches
+}
+
+// suggest rewrites the raw query with the words the corrected terms were
+// indexed from, keeping filters and words that weren't corrected as they
+// are. Returns "" if nothing changed.
+func (i *Indexer) suggest(rawQuery string, corrections map[string]string, analyzer *Analyzer) string {
+	if len(corrections) == 0 {
+		return ""
+	}
+	words := strings.Fields(rawQuery)
+	changed := false
+	for n, word := range words {
+		tokens := analyzer.Analyze([]rune(word))
+		if len(tokens) != 1 {
+			continue
+		}
+		if correction, ok := corrections[tokens[0]]; ok {
+			words[n] = i.surfaceOf(correction)
+			changed = true
+		}
+	}
+	if !changed {
+		return ""
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{a: "", b: "", expected: 0},
+		{a: "", b: "abc", expected: 3},
+		{a: "deploy", b: "deploy", expected: 0},
+		{a: "deploy", b: "deplyo", expected: 2},
+		{a: "kitten", b: "sitting", expected: 3},
+		{a: "straße", b: "strasse", expected: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			if got := levenshtein(tt.a, tt.b); got != tt.expected {
+				t.Errorf("expected %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestBKTree_search(t *testing.T) {
+	terms := []string{"book", "books", "cake", "boo", "cape", "cart", "boon", "cook"}
+	tree := newBKTree(terms)
+
+	got := make([]string, 0)
+	for _, match := range tree.search("bool", 1) {
+		got = append(got, match.term)
+	}
+	sort.Strings(got)
+
+	expected := []string{"boo", "book", "boon"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestIndexer_SearchFuzzy(t *testing.T) {
+	indexer, dir := newMemoryIndexer(t, map[string]string{
+		"kube.md":  "kubernetes cluster upgrade",
+		"db.md":    "database migration",
+		"other.md": "lunch menu",
+	})
+
+	response := indexer.Search("kubrnetes type:md")
+	if len(response.Results) == 0 || response.Results[0].Path() != filepath.Join(dir, "kube.md") {
+		t.Fatalf("expected kube.md as top result, got %+v", response.Results)
+	}
+	// the word, not the stemmed term
+	if response.Suggestion != "kubernetes type:md" {
+		t.Errorf("expected suggestion %q, got %q", "kubernetes type:md", response.Suggestion)
+	}
+
+	exact := indexer.Search("kubernetes").Results[0].Rank()
+	if fuzzy := response.Results[0].Rank(); fuzzy >= exact {
+		t.Errorf("expected fuzzy rank %f to be lower than exact rank %f", fuzzy, exact)
+	}
+
+	if suggestion := indexer.Search("database").Suggestion; suggestion != "" {
+		t.Errorf("expected no suggestion for an exact match, got %q", suggestion)
+	}
+
+	indexer.opts.Fuzzy = false
+	if results := indexer.SearchQuery("kubrnetes"); len(results) > 0 && results[0].Rank() > 0 {
+		t.Errorf("expected no matches with fuzzy matching disabled, got %+v", results)
+	}
+}
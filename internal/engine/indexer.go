@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"sync"
 
 	"github.com/gfxv/scout/internal/database"
@@ -16,86 +17,318 @@ const pathsBufferSize = 200
 const numIndexWorkers = 10
 
 type Indexer struct {
+	opts       Options
+	extensions map[string]string
+	// commands are the external extractors by extension, run by runner,
+	// those that aren't installed are dropped by checkCommands
+	commands  map[string][]string
+	runner    *commandRunner
+	checkOnce *sync.Once
+
+	analyzer            *Analyzer
+	collectionAnalyzers map[string]*Analyzer
+
+	bufMu     *sync.Mutex
 	buffer    []database.DocumentData
 	batchSize int
-	db        *database.Database
+	store     database.IndexStore
 
 	diMu          *sync.Mutex
 	dfMu          *sync.Mutex
 	documentIndex models.DocIndex
-	docFrequency  models.TermFreq
+	// docPaths maps document IDs to paths, built by Load
+	docPaths map[int]string
+	// duplicates maps documents to the first path of their group of
+	// duplicates, built by Load if CollapseDuplicates is on
+	duplicates   map[string]string
+	docFrequency models.TermFreq
+	// per collection document frequencies and sizes, so searching
+	// a single collection ranks with that collection's idf
+	collectionFreq map[string]models.TermFreq
+	collectionSize map[string]int
+	// terms and termTree hold all indexed terms for wildcard
+	// and fuzzy matching, built by Load
+	terms    termDictionary
+	termTree *bkTree
+	// surfaces map terms to the words they were indexed from, words
+	// are those words for completing them, built by Load
+	surfaces map[string]string
+	words    surfaceDictionary
+
+	history *queryHistory
+
+	synMu    *sync.Mutex
+	synonyms map[*Analyzer]synonyms
 }
 
-func NewIndexer(dbPath string) *Indexer {
-	db, err := database.NewDatabase(dbPath)
+// NewIndexer creates an indexer backed by the database described by dsn,
+// either a SQLite file path or a postgres:// URL.
+func NewIndexer(dsn string) (*Indexer, error) {
+	db, err := database.Open(dsn)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("failed to open database, err: %w", err)
 	}
+	indexer, err := NewIndexerWithStore(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return indexer, nil
+}
+
+// NewIndexerWithStore creates an indexer with default options on top of an
+// arbitrary store, e.g. database.NewMemoryStore() for tests or ephemeral indexes.
+func NewIndexerWithStore(store database.IndexStore) (*Indexer, error) {
+	return NewIndexerWithOptions(store, DefaultOptions())
+}
+
+func NewIndexerWithOptions(store database.IndexStore, opts Options) (*Indexer, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	analyzer, collectionAnalyzers, err := opts.analyzers()
+	if err != nil {
+		return nil, err
+	}
+
+	indexer := &Indexer{
+		opts:       opts,
+		extensions: opts.extensions(),
+		commands:   opts.commands(),
+		runner:     newCommandRunner(opts.CommandTimeout, opts.CommandMaxOutput),
+		checkOnce:  &sync.Once{},
+
+		analyzer:            analyzer,
+		collectionAnalyzers: collectionAnalyzers,
 
-	return &Indexer{
+		bufMu:     &sync.Mutex{},
 		buffer:    make([]database.DocumentData, 0),
 		batchSize: 50,
-		db:        db,
+		store:     store,
 
 		diMu:          &sync.Mutex{},
 		dfMu:          &sync.Mutex{},
 		documentIndex: make(models.DocIndex),
+		docPaths:      make(map[int]string),
+		duplicates:    make(map[string]string),
 		docFrequency:  make(models.TermFreq),
+
+		collectionFreq: make(map[string]models.TermFreq),
+		collectionSize: make(map[string]int),
+
+		history: newQueryHistory(),
+
+		synMu: &sync.Mutex{},
 	}
+	if err := indexer.ReloadSynonyms(); err != nil {
+		return nil, err
+	}
+	return indexer, nil
+}
+
+// SearchQuery ranks the documents of the given collections, or of all
+// collections if none are given.
+func (i *Indexer) SearchQuery(query string, collections ...string) []models.SearchQueryResult {
+	return i.Search(query, collections...).Results
 }
 
-func (i *Indexer) SearchQuery(query string) []models.SearchQueryResult {
+// Search is like SearchQuery, but also returns facet counts of the results.
+// The query may contain filters, see ParseQuery.
+func (i *Indexer) Search(rawQuery string, collections ...string) models.SearchResponse {
 	result := make([]models.SearchQueryResult, 0)
-	tokenizer := NewTokenizer([]rune(query))
-	tokens := make([]string, 0)
+	query := ParseQuery(rawQuery)
+	scope := i.newSearchScope(collections)
+	// collections may analyze text differently, so the query
+	// is expanded once per analyzer of the matching documents
+	expansions := make(map[*Analyzer]queryExpansion)
 
-	for {
-		token, ok := tokenizer.NextToken()
+	for path, docInfo := range i.documentIndex {
+		if !scope.contains(docInfo.Collection) || !query.matches(docInfo) {
+			continue
+		}
+		analyzer := i.analyzerFor(docInfo.Collection)
+		expansion, ok := expansions[analyzer]
 		if !ok {
-			break
+			expansion = i.expandTerms(query, scope, analyzer)
+			expansions[analyzer] = expansion
 		}
-		tokens = append(tokens, token)
-	}
-
-	for path, docInfo := range i.documentIndex {
 		rank := float32(0)
-		for _, token := range tokens {
-			tf := i.tf(token, docInfo)
-			idf := i.idf(token)
-			rank += tf * idf
+		for _, term := range expansion.terms {
+			tf := i.tf(term.term, docInfo)
+			idf := i.idf(term.term, scope)
+			rank += term.weight * tf * idf
+		}
+		if rank < i.opts.MinScore {
+			continue
 		}
-		result = append(result, models.NewSearchQueryResult(path, rank))
+		result = append(result, models.NewSearchQueryResult(path, docInfo, rank))
 	}
 
 	sort.Slice(result, func(i, j int) bool {
 		return result[i].Rank() > result[j].Rank()
 	})
+	result = groupPassages(result)
+	if i.opts.CollapseDuplicates {
+		result = i.collapseDuplicates(result)
+	}
+
+	// with search terms only actual matches are counted,
+	// a query with filters only counts all filtered documents
+	matching := result
+	if strings.TrimSpace(query.Text) != "" || len(query.Wildcards) > 0 {
+		matching = result[:sort.Search(len(result), func(i int) bool { return result[i].Rank() <= 0 })]
+	}
+	facets := buildFacets(i.documentIndex, i.docPaths, matching)
+
+	if i.opts.MaxResults > 0 && len(result) > i.opts.MaxResults {
+		result = result[:i.opts.MaxResults]
+	}
+	suggestion := ""
+	for _, analyzer := range i.allAnalyzers() {
+		if expansion, ok := expansions[analyzer]; ok && suggestion == "" {
+			suggestion = i.suggest(rawQuery, expansion.corrections, analyzer)
+		}
+	}
+
+	return models.SearchResponse{
+		Results:    result,
+		Facets:     facets,
+		Suggestion: suggestion,
+	}
+}
+
+// queryExpansion is a query analyzed and expanded for one analyzer.
+type queryExpansion struct {
+	terms []queryTerm
+	// corrections map misspelt terms to the closest indexed term
+	corrections map[string]string
+}
+
+// expandTerms tokenizes the query text and expands wildcard patterns to the
+// matching indexed terms, and terms to their synonyms. With fuzzy matching
+// enabled, terms missing from the searched collections (with no synonyms in
+// them either) are expanded to the closest indexed terms, which are also
+// returned as corrections for a "did you mean" suggestion.
+func (i *Indexer) expandTerms(query Query, scope searchScope, analyzer *Analyzer) queryExpansion {
+	terms := make([]queryTerm, 0)
+	for _, pattern := range query.Wildcards {
+		for _, term := range i.wildcardMatches(pattern, scope, analyzer) {
+			terms = append(terms, queryTerm{term: term, weight: 1})
+		}
+	}
+
+	corrections := make(map[string]string)
+	for _, token := range analyzer.Analyze([]rune(query.Text)) {
+		terms = append(terms, queryTerm{term: token, weight: 1})
+		found := scope.docFrequency(token) > 0
+		for _, synonym := range i.synonymsOf(token, analyzer) {
+			terms = append(terms, queryTerm{term: synonym, weight: i.opts.SynonymWeight})
+			found = found || scope.docFrequency(synonym) > 0
+		}
+		if !i.opts.Fuzzy || found {
+			continue
+		}
+		matches := i.fuzzyMatches(token, scope)
+		for _, match := range matches {
+			terms = append(terms, queryTerm{term: match.term, weight: fuzzyWeight(match.distance)})
+		}
+		if len(matches) > 0 {
+			corrections[token] = matches[0].term
+		}
+	}
+	return queryExpansion{terms: terms, corrections: corrections}
+}
+
+// analyzerFor returns the analyzer the collection is indexed and searched with.
+func (i *Indexer) analyzerFor(collection string) *Analyzer {
+	if analyzer, ok := i.collectionAnalyzers[collection]; ok {
+		return analyzer
+	}
+	return i.analyzer
+}
+
+// allAnalyzers returns the default analyzer followed by those of the collections.
+func (i *Indexer) allAnalyzers() []*Analyzer {
+	analyzers := []*Analyzer{i.analyzer}
+	for _, collection := range sortedKeys(i.collectionAnalyzers) {
+		analyzers = append(analyzers, i.collectionAnalyzers[collection])
+	}
+	return analyzers
+}
 
-	return result
+// Collections returns all collections with their statistics.
+func (i *Indexer) Collections() ([]models.CollectionStats, error) {
+	return i.store.GetCollections()
 }
 
 func (i *Indexer) Load() error {
-	docIndex, docFreq, err := i.db.LoadIndexData()
+	docIndex, docFreq, err := i.store.LoadIndexData()
 	if err != nil {
 		return err
 	}
 
+	docPaths := make(map[int]string, len(docIndex))
+	for path, docInfo := range docIndex {
+		docPaths[docInfo.ID] = path
+	}
+
+	duplicates := make(map[string]string)
+	if i.opts.CollapseDuplicates {
+		duplicates = duplicateOf(docIndex)
+	}
+
 	i.diMu.Lock()
 	i.documentIndex = docIndex
+	i.docPaths = docPaths
+	i.duplicates = duplicates
 	i.diMu.Unlock()
 
+	surfaces, err := i.store.GetSurfaceForms()
+	if err != nil {
+		return err
+	}
+
+	collectionFreq, collectionSize := buildCollectionFrequency(docIndex)
+	terms := newTermDictionary(docFreq)
+	var termTree *bkTree
+	if i.opts.Fuzzy {
+		termTree = newBKTree(terms)
+	}
+	words := newSurfaceDictionary(terms, surfaces)
+
 	i.dfMu.Lock()
 	i.docFrequency = docFreq
+	i.collectionFreq = collectionFreq
+	i.collectionSize = collectionSize
+	i.terms = terms
+	i.termTree = termTree
+	i.surfaces = surfaces
+	i.words = words
 	i.dfMu.Unlock()
 
 	return nil
 }
 
-func (i *Indexer) IndexDir(path string) error {
+// IndexDir indexes all files under root into the collection,
+// an empty collection name means models.DefaultCollection.
+func (i *Indexer) IndexDir(collection, root string) error {
+	return i.IndexSource(Source{Collection: collection, Root: root})
+}
+
+// IndexSource indexes the files of the source matching its include/exclude rules.
+func (i *Indexer) IndexSource(source Source) error {
+	if source.Collection == "" {
+		source.Collection = models.DefaultCollection
+	}
+	if err := source.Validate(); err != nil {
+		return err
+	}
+	i.checkCommands()
+
 	filesChan := make(chan string, pathsBufferSize)
 	go func() {
-		if err := collectFiles(path, filesChan); err != nil {
-			fmt.Printf("error occurred during collecting files, err: %w", err)
+		if err := collectFiles(source, i.opts.Archives, filesChan); err != nil {
+			fmt.Printf("error occurred during collecting files, err: %v\n", err)
 		}
 	}()
 
@@ -103,52 +336,97 @@ func (i *Indexer) IndexDir(path string) error {
 	for w := 0; w < numIndexWorkers; w++ {
 		wg.Add(1)
 		go func(i *Indexer) {
-			i.indexWorker(&wg, filesChan)
+			i.indexWorker(&wg, source, filesChan)
 		}(i)
 	}
 	wg.Wait()
 	i.Flush()
+
+	fingerprint := i.analyzerFor(source.Collection).Fingerprint()
+	if err := i.store.SaveCollection(source.Collection, source.Root, fingerprint); err != nil {
+		return fmt.Errorf("failed to save collection %s, err: %w", source.Collection, err)
+	}
 	return nil
 }
 
-func (i *Indexer) IndexFile(path string) error {
+// IndexFile indexes the file, or the files in it if it's an archive and
+// Archives is on.
+func (i *Indexer) IndexFile(collection, path string) error {
+	i.checkCommands()
+	if i.opts.Archives && isArchive(path) {
+		return i.indexArchive(collection, path, nil)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return i.indexFile(collection, path, path, info)
+}
+
+// indexFile indexes the file at local under path, they differ for files
+// extracted from archives, whose path is virtual.
+func (i *Indexer) indexFile(collection, path, local string, info os.FileInfo) error {
 	fmt.Printf("Indexing %s...\n", path)
 
-	// TODO:
-	// - may be change switch-case to map ? (command pattern)
-	var content []rune
-	var err error
-	switch filepath.Ext(path) {
-	case ".md", ".txt":
-		content, err = plainTextReader(path)
-	case ".xml", ".xhtml":
-		content, err = xmlReader(path)
-	case ".pdf":
-		content, err = pdfReader(path)
-	case ".html":
-		content, err = htmlReader(path)
-	default:
+	reader, ok := i.readerFor(path)
+	if !ok {
 		fmt.Printf("Unknown file type %s\n", path)
 		return nil
 	}
+	if i.opts.MaxFileSize > 0 && info.Size() > i.opts.MaxFileSize {
+		fmt.Printf("Skipping %s, file is larger than %d bytes\n", path, i.opts.MaxFileSize)
+		return nil
+	}
 
+	extracted, err := i.extractText(local, reader)
 	if err != nil {
 		return err
 	}
-
-	tokenizer := NewTokenizer(content)
-	terms := make([]string, 0)
-	for {
-		token, ok := tokenizer.NextToken()
-		if !ok {
-			break
+	content := extracted.content
+
+	doc := database.DocumentData{
+		Collection:  collection,
+		Path:        path,
+		ModTime:     info.ModTime(),
+		ContentHash: extracted.hash,
+		NoText:      extracted.noText,
+		FailedPages: extracted.failedPages,
+	}
+	if doc.NoText {
+		fmt.Printf("No text extracted from %s\n", path)
+	}
+	if i.opts.StoreText && !extracted.stored {
+		doc.Text = string(content)
+	}
+	doc.SimHash = simHash(content)
+	analyzer := i.analyzerFor(collection)
+
+	docs := make([]database.DocumentData, 0, 1)
+	for n, passage := range i.splitPassages(path, content) {
+		passageDoc := doc
+		passageDoc.Path = path + "#" + passage.anchor
+		passageDoc.Parent = path
+		passageDoc.Passage = passage.label
+		passageDoc.Page = passage.page
+		passageDoc.Terms = analyzer.Analyze(passage.text)
+		// the text is the file's, it's stored once
+		if n > 0 {
+			passageDoc.Text = ""
 		}
-		terms = append(terms, token)
+		docs = append(docs, passageDoc)
+	}
+	if len(docs) == 0 {
+		doc.Terms = analyzer.Analyze(content)
+		docs = append(docs, doc)
 	}
+	// the surface forms of the file's terms, once
+	docs[0].Surfaces = analyzer.SurfaceForms(content)
 
-	i.buffer = append(i.buffer, database.DocumentData{Path: path, Terms: terms})
+	i.bufMu.Lock()
+	defer i.bufMu.Unlock()
+	i.buffer = append(i.buffer, docs...)
 	if len(i.buffer) >= i.batchSize {
-		if err := i.db.AddDocuments(i.buffer); err != nil {
+		if err := i.store.AddDocuments(i.buffer); err != nil {
 			fmt.Printf("failed to add documents, err: %v", err)
 		}
 		i.buffer = nil
@@ -158,44 +436,115 @@ func (i *Indexer) IndexFile(path string) error {
 
 // Flush processes any remaining documents in the buffer
 func (i *Indexer) Flush() {
+	i.bufMu.Lock()
+	defer i.bufMu.Unlock()
 	if len(i.buffer) > 0 {
-		if err := i.db.AddDocuments(i.buffer); err != nil {
+		if err := i.store.AddDocuments(i.buffer); err != nil {
 			fmt.Printf("failed to add documents, err: %v", err)
 		}
 		i.buffer = nil
 	}
 }
 
+// RemoveFile drops the document, or all passages of the file,
+// from both the store and the in-memory index.
 func (i *Indexer) RemoveFile(path string) error {
-	docInfo, ok := i.documentIndex[path]
-	if !ok {
+	i.diMu.Lock()
+	defer i.diMu.Unlock()
+	i.dfMu.Lock()
+	defer i.dfMu.Unlock()
+
+	removed := make(models.DocIndex)
+	if docInfo, ok := i.documentIndex[path]; ok {
+		removed[path] = docInfo
+	} else {
+		for docPath, docInfo := range i.documentIndex {
+			if docInfo.Parent == path {
+				removed[docPath] = docInfo
+			}
+		}
+	}
+	if len(removed) == 0 {
 		return fmt.Errorf("path %s not found", path)
 	}
 
-	for term := range docInfo.Terms {
-		if i.docFrequency[term] > 0 {
-			i.docFrequency[term]--
+	if err := i.store.RemoveDocument(path); err != nil {
+		return fmt.Errorf("failed to remove %s from store, err: %w", path, err)
+	}
+
+	for docPath, docInfo := range removed {
+		collectionFreq := i.collectionFreq[docInfo.Collection]
+		for term := range docInfo.Terms {
+			decrementFreq(i.docFrequency, term)
+			decrementFreq(collectionFreq, term)
 		}
-		if i.docFrequency[term] == 0 {
-			delete(i.docFrequency, term)
+		i.collectionSize[docInfo.Collection]--
+
+		delete(i.documentIndex, docPath)
+		delete(i.docPaths, docInfo.ID)
+	}
+	delete(i.duplicates, path)
+	return nil
+}
+
+// readerFor returns the external command or reader extracting the file's text.
+func (i *Indexer) readerFor(path string) (readerFunc, bool) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if command, ok := i.commands[ext]; ok {
+		// the command isn't installed, see checkCommands
+		if len(command) == 0 {
+			return nil, false
 		}
+		return i.runner.reader(command), true
 	}
+	reader, ok := readers[i.extensions[ext]]
+	return reader, ok
+}
 
-	delete(i.documentIndex, path)
-	return nil
+// checkCommands warns about external extractors that aren't installed, once,
+// when indexing starts. Files of their extensions are skipped as of unknown
+// type, PDFs are read without the fallback.
+func (i *Indexer) checkCommands() {
+	i.checkOnce.Do(func() {
+		for _, ext := range sortedKeys(i.commands) {
+			if err := lookCommand(i.commands[ext]); err != nil {
+				fmt.Printf("Skipping %s files, %v\n", ext, err)
+				i.commands[ext] = nil
+			}
+		}
+		if len(i.opts.PDFFallback) > 0 {
+			if err := lookCommand(i.opts.PDFFallback); err != nil {
+				fmt.Printf("Reading PDFs without the fallback, %v\n", err)
+				i.opts.PDFFallback = nil
+			}
+		}
+	})
 }
 
-func (i *Indexer) indexWorker(wg *sync.WaitGroup, paths <-chan string) {
+// Close releases the underlying store.
+func (i *Indexer) Close() error {
+	return i.store.Close()
+}
+
+func (i *Indexer) indexWorker(wg *sync.WaitGroup, source Source, paths <-chan string) {
 	defer wg.Done()
 	for path := range paths {
-		if err := i.IndexFile(path); err != nil {
+		var err error
+		if i.opts.Archives && isArchive(path) {
+			err = i.indexArchive(source.Collection, path, source.matches)
+		} else {
+			err = i.IndexFile(source.Collection, path)
+		}
+		if err != nil {
 			// TODO: write errors to errChan
 			fmt.Println(err)
 		}
 	}
 }
 
-func collectFiles(root string, out chan<- string) error {
+// collectFiles sends the files of the source to out, with archives the
+// include patterns are matched by the files inside them, see indexArchive.
+func collectFiles(source Source, archives bool, out chan<- string) error {
 	defer close(out)
 
 	walkFunc := func(path string, info os.FileInfo, err error) error {
@@ -205,11 +554,20 @@ func collectFiles(root string, out chan<- string) error {
 		if info.IsDir() {
 			return nil
 		}
+		if archives && isArchive(path) {
+			if !source.excludes(path) {
+				out <- path
+			}
+			return nil
+		}
+		if !source.matches(path) {
+			return nil
+		}
 
 		out <- path
 		return nil
 	}
-	if err := filepath.Walk(root, walkFunc); err != nil {
+	if err := filepath.Walk(source.Root, walkFunc); err != nil {
 		return err
 	}
 	return nil
@@ -223,26 +581,22 @@ func (i *Indexer) tf(token string, docInfo models.DocInfo) float32 {
 	return float32(freq) / float32(docInfo.TotalTerms)
 }
 
-func (i *Indexer) idf(token string) float32 {
-	totalDocs := len(i.documentIndex)
+func (i *Indexer) idf(token string, scope searchScope) float32 {
+	totalDocs := scope.totalDocs
 	if totalDocs == 0 {
 		totalDocs = 1
 	}
-	docsWithTerm := i.docFrequency[token]
+	docsWithTerm := scope.docFrequency(token)
 	return float32(math.Log(float64(totalDocs+1) / float64(docsWithTerm+1)))
 }
 
-func tokenizeQuery(query string) []string {
-	tokenizer := NewTokenizer([]rune(query))
-	tokens := make([]string, 0)
-	for {
-		token, ok := tokenizer.NextToken()
-		if !ok {
-			break
-		}
-		tokens = append(tokens, token)
+func decrementFreq(freq models.TermFreq, term string) {
+	if freq[term] > 0 {
+		freq[term]--
+	}
+	if freq[term] == 0 {
+		delete(freq, term)
 	}
-	return tokens
 }
 
 func (i *Indexer) PrettyPrint() {
@@ -0,0 +1,216 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gfxv/scout/internal/database"
+	"github.com/gfxv/scout/internal/models"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func newTestStore() database.IndexStore {
+	return database.NewMemoryStore()
+}
+
+func newMemoryIndexer(t *testing.T, files map[string]string) (*Indexer, string) {
+	t.Helper()
+	dir := writeFiles(t, files)
+	indexer, err := NewIndexerWithStore(database.NewMemoryStore())
+	if err != nil {
+		t.Fatalf("NewIndexerWithStore failed: %v", err)
+	}
+	if err := indexer.IndexDir(models.DefaultCollection, dir); err != nil {
+		t.Fatalf("IndexDir failed: %v", err)
+	}
+	if err := indexer.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	return indexer, dir
+}
+
+func TestIndexer_SearchQuery(t *testing.T) {
+	indexer, dir := newMemoryIndexer(t, map[string]string{
+		"go.md":      "go is a programming language, go go go",
+		"rust.txt":   "rust is a programming language too",
+		"cooking.md": "how to cook pasta",
+	})
+
+	results := indexer.SearchQuery("go")
+	if len(results) == 0 {
+		t.Fatal("expected results, got none")
+	}
+	if want := filepath.Join(dir, "go.md"); results[0].Path() != want {
+		t.Errorf("expected top result %q, got %q", want, results[0].Path())
+	}
+}
+
+func TestIndexer_RemoveFile(t *testing.T) {
+	indexer, dir := newMemoryIndexer(t, map[string]string{
+		"a.txt": "alpha beta",
+		"b.txt": "beta gamma",
+	})
+
+	path := filepath.Join(dir, "a.txt")
+	if err := indexer.RemoveFile(path); err != nil {
+		t.Fatalf("RemoveFile failed: %v", err)
+	}
+	if err := indexer.RemoveFile(path); err == nil {
+		t.Error("expected error when removing a missing file")
+	}
+
+	// reload from the store to make sure removal was persisted
+	if err := indexer.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if _, ok := indexer.documentIndex[path]; ok {
+		t.Errorf("expected %q to be removed from the index", path)
+	}
+	if _, ok := indexer.docFrequency["alpha"]; ok {
+		t.Error("expected term \"alpha\" to be removed from doc frequency")
+	}
+	if got := indexer.docFrequency["beta"]; got != 1 {
+		t.Errorf("expected doc frequency of \"beta\" = 1, got %d", got)
+	}
+}
+
+func TestIndexer_IndexAgain(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.txt": "alpha beta",
+		"b.txt": "beta gamma",
+	})
+	db, err := database.NewDatabase(filepath.Join(t.TempDir(), "meta.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	indexer, err := NewIndexerWithStore(db)
+	if err != nil {
+		t.Fatalf("NewIndexerWithStore failed: %v", err)
+	}
+	defer indexer.Close()
+	if err := indexer.IndexDir(models.DefaultCollection, dir); err != nil {
+		t.Fatalf("IndexDir failed: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("alpha delta"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "c.txt"), []byte("delta epsilon"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := indexer.IndexDir(models.DefaultCollection, dir); err != nil {
+		t.Fatalf("IndexDir failed: %v", err)
+	}
+	if err := indexer.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if len(indexer.documentIndex) != 3 {
+		t.Errorf("expected 3 documents, got %d", len(indexer.documentIndex))
+	}
+	expected := map[string]uint{"alpha": 1, "beta": 1, "gamma": 1, "delta": 2, "epsilon": 1}
+	for term, count := range expected {
+		if got := indexer.docFrequency[term]; got != count {
+			t.Errorf("expected doc frequency of %q = %d, got %d", term, count, got)
+		}
+	}
+	for _, result := range indexer.SearchQuery("beta") {
+		if result.Rank() > 0 && result.Path() != filepath.Join(dir, "b.txt") {
+			t.Errorf("expected only b.txt to still contain beta, got %s", result.Path())
+		}
+	}
+}
+
+func TestIndexer_Collections(t *testing.T) {
+	specs := writeFiles(t, map[string]string{
+		"api.md":  "the api returns json",
+		"auth.md": "auth tokens expire",
+	})
+	runbooks := writeFiles(t, map[string]string{
+		"restart.md": "restart the api server",
+	})
+
+	indexer, err := NewIndexerWithStore(database.NewMemoryStore())
+	if err != nil {
+		t.Fatalf("NewIndexerWithStore failed: %v", err)
+	}
+	if err := indexer.IndexDir("specs", specs); err != nil {
+		t.Fatalf("IndexDir failed: %v", err)
+	}
+	if err := indexer.IndexDir("runbooks", runbooks); err != nil {
+		t.Fatalf("IndexDir failed: %v", err)
+	}
+	if err := indexer.IndexDir("Bad Name", runbooks); err == nil {
+		t.Error("expected error for invalid collection name")
+	}
+	if err := indexer.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	all := indexer.SearchQuery("api")
+	if len(all) != 3 {
+		t.Errorf("expected 3 results across all collections, got %d", len(all))
+	}
+
+	results := indexer.SearchQuery("api", "runbooks")
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result in runbooks, got %d", len(results))
+	}
+	if results[0].Collection() != "runbooks" || results[0].Path() != filepath.Join(runbooks, "restart.md") {
+		t.Errorf("unexpected result %q in collection %q", results[0].Path(), results[0].Collection())
+	}
+
+	collections, err := indexer.Collections()
+	if err != nil {
+		t.Fatalf("Collections failed: %v", err)
+	}
+	if len(collections) != 2 || collections[0].Documents != 1 || collections[1].Documents != 2 {
+		t.Errorf("unexpected collections: %+v", collections)
+	}
+}
+
+func TestIndexer_SearchCompound(t *testing.T) {
+	indexer, dir := newMemoryIndexer(t, map[string]string{
+		"upgrade.md":  "upgrade the cluster to v1.23.3, then ping 10.0.0.1",
+		"previous.md": "upgrade the cluster to v1.22.0 and version 3 of the chart",
+		"errors.md":   "ERR-4012 means the disk is full, mail ops@example.com",
+	})
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{query: "v1.23.3", expected: "upgrade.md"},
+		{query: "10.0.0.1", expected: "upgrade.md"},
+		{query: "err-4012", expected: "errors.md"},
+		{query: "ops@example.com", expected: "errors.md"},
+		{query: "v1.22*", expected: "previous.md"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			results := indexer.SearchQuery(tt.query)
+			if len(results) == 0 || results[0].Rank() == 0 {
+				t.Fatal("expected results, got none")
+			}
+			if want := filepath.Join(dir, tt.expected); results[0].Path() != want {
+				t.Errorf("expected top result %q, got %q", want, results[0].Path())
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+type Options struct {
+	// Language used for stemming, both when indexing and searching.
+	Language string
+	// MaxResults limits the number of search results, 0 means no limit.
+	MaxResults int
+	// MinScore drops search results ranked below it.
+	MinScore float32
+	// Extensions maps additional file extensions (e.g. ".rst") to one of ReaderNames.
+	Extensions map[string]string
+	// MaxFileSize skips larger files while indexing, 0 means no limit.
+	MaxFileSize int64
+	// Commands maps file extensions (e.g. ".rtf") to external commands
+	// extracting their text, e.g. ["unrtf", "--text", "{path}"]. They get
+	// the file's path in place of {path} and must print the text to stdout.
+	// A command takes precedence over a reader of the same extension.
+	Commands map[string][]string
+	// CommandTimeout kills external commands running longer, 0 means 30s.
+	CommandTimeout time.Duration
+	// CommandMaxOutput fails external commands printing more bytes, 0 means 64 MiB.
+	CommandMaxOutput int64
+	// PDFFallback is an external command extracting the text of PDFs the
+	// built-in reader gets no text (or not all pages) from, e.g.
+	// ["pdftotext", "{path}", "-"]. It gets the file's path in place of
+	// {path} and must print the text, pages separated by form feeds.
+	PDFFallback []string
+	// Archives descends into .zip, .tar.gz and .tgz archives while indexing,
+	// indexing the files in them under virtual paths like
+	// "bundle.zip!/docs/a.md". Include and exclude patterns match those paths.
+	Archives bool
+	// ArchiveMaxEntrySize fails files in archives extracting to more bytes,
+	// 0 means 64 MiB. MaxFileSize applies too if it's lower.
+	ArchiveMaxEntrySize int64
+	// ArchiveMaxSize stops indexing an archive once more bytes were
+	// extracted from it, 0 means 1 GiB.
+	ArchiveMaxSize int64
+	// StoreText keeps the extracted text of indexed files in the store,
+	// so it doesn't have to be extracted again, see DocumentView.
+	StoreText bool
+	// Passages splits long files into passages indexed as documents of
+	// their own, so results can point at the best matching page or
+	// section, one of PassageModes or "" to index files as a whole.
+	Passages string
+	// PassageWords is the size of passages split by word count, 0 means 200.
+	PassageWords int
+	// CollapseDuplicates shows only the best ranked of duplicate documents
+	// in search results, see Duplicates.
+	CollapseDuplicates bool
+	// Fuzzy expands query terms that aren't indexed to similarly spelt
+	// indexed terms, ranked lower than exact matches.
+	Fuzzy bool
+	// SynonymsFile is read on start and by ReloadSynonyms, see readSynonyms for the format.
+	SynonymsFile string
+	// SynonymWeight is the weight of a synonym relative to the term it was expanded from.
+	SynonymWeight float32
+	// Analyzer turns text into terms for collections without their own analyzer.
+	Analyzer AnalyzerConfig
+	// CollectionAnalyzers are the analyzers of specific collections.
+	CollectionAnalyzers map[string]AnalyzerConfig
+}
+
+func DefaultOptions() Options {
+	return Options{
+		Language: DefaultLanguage,
+		Fuzzy:    true,
+
+		SynonymWeight: 0.5,
+	}
+}
+
+func (o Options) Validate() error {
+	if err := ValidateLanguage(o.Language); err != nil {
+		return err
+	}
+	if o.MaxResults < 0 {
+		return fmt.Errorf("max results can't be negative")
+	}
+	if o.MaxFileSize < 0 {
+		return fmt.Errorf("max file size can't be negative")
+	}
+	if o.Passages != "" && !slices.Contains(PassageModes(), o.Passages) {
+		return fmt.Errorf("unknown passages mode %q (available: %s)", o.Passages, strings.Join(PassageModes(), ", "))
+	}
+	if o.PassageWords < 0 {
+		return fmt.Errorf("passage words can't be negative")
+	}
+	if o.SynonymWeight <= 0 || o.SynonymWeight > 1 {
+		return fmt.Errorf("synonym weight must be above 0 and at most 1, got %v", o.SynonymWeight)
+	}
+	analyzer, _, err := o.analyzers()
+	if err != nil {
+		return err
+	}
+	if o.SynonymsFile != "" {
+		if _, err := readSynonyms(o.SynonymsFile, analyzer); err != nil {
+			return err
+		}
+	}
+	if o.CommandTimeout < 0 {
+		return fmt.Errorf("command timeout can't be negative")
+	}
+	if o.CommandMaxOutput < 0 {
+		return fmt.Errorf("command max output can't be negative")
+	}
+	if o.ArchiveMaxEntrySize < 0 {
+		return fmt.Errorf("archive max entry size can't be negative")
+	}
+	if o.ArchiveMaxSize < 0 {
+		return fmt.Errorf("archive max size can't be negative")
+	}
+	for ext, command := range o.Commands {
+		if !strings.HasPrefix(ext, ".") {
+			return fmt.Errorf("extension %q must start with a dot", ext)
+		}
+		if err := validateCommand(command); err != nil {
+			return fmt.Errorf("invalid command for extension %s, %w", ext, err)
+		}
+	}
+	if len(o.PDFFallback) > 0 {
+		if err := validateCommand(o.PDFFallback); err != nil {
+			return fmt.Errorf("invalid PDF fallback, %w", err)
+		}
+	}
+	for ext, reader := range o.Extensions {
+		if !strings.HasPrefix(ext, ".") {
+			return fmt.Errorf("extension %q must start with a dot", ext)
+		}
+		if _, ok := readers[reader]; !ok {
+			return fmt.Errorf("unknown reader %q for extension %s (available: %s)", reader, ext, strings.Join(ReaderNames(), ", "))
+		}
+	}
+	return nil
+}
+
+// CheckCommands checks that the external extractors are installed. They
+// only run while indexing, so Validate doesn't require them to be, files
+// whose extractor is missing aren't indexed, see Indexer.checkCommands.
+func (o Options) CheckCommands() error {
+	errs := make([]error, 0)
+	for _, ext := range sortedKeys(o.Commands) {
+		if err := lookCommand(o.Commands[ext]); err != nil {
+			errs = append(errs, fmt.Errorf("invalid command for extension %s, %w", ext, err))
+		}
+	}
+	if len(o.PDFFallback) > 0 {
+		if err := lookCommand(o.PDFFallback); err != nil {
+			errs = append(errs, fmt.Errorf("invalid PDF fallback, %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// analyzers builds the default analyzer and those of the collections.
+func (o Options) analyzers() (*Analyzer, map[string]*Analyzer, error) {
+	analyzer, err := NewAnalyzer(o.Analyzer, o.Language)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid analyzer, %w", err)
+	}
+	collections := make(map[string]*Analyzer, len(o.CollectionAnalyzers))
+	for collection, config := range o.CollectionAnalyzers {
+		if err := ValidateCollectionName(collection); err != nil {
+			return nil, nil, err
+		}
+		if collections[collection], err = NewAnalyzer(config, o.Language); err != nil {
+			return nil, nil, fmt.Errorf("invalid analyzer of collection %s, %w", collection, err)
+		}
+	}
+	return analyzer, collections, nil
+}
+
+// commands returns the external commands by lowercase extension.
+func (o Options) commands() map[string][]string {
+	commands := make(map[string][]string, len(o.Commands))
+	for ext, command := range o.Commands {
+		commands[strings.ToLower(ext)] = command
+	}
+	return commands
+}
+
+// extensions merges the configured extensions over the default ones.
+func (o Options) extensions() map[string]string {
+	extensions := make(map[string]string, len(defaultExtensions)+len(o.Extensions))
+	for ext, reader := range defaultExtensions {
+		extensions[ext] = reader
+	}
+	for ext, reader := range o.Extensions {
+		extensions[strings.ToLower(ext)] = reader
+	}
+	return extensions
+}
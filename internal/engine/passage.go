@@ -0,0 +1,221 @@
+package engine
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/gfxv/scout/internal/models"
+)
+
+const (
+	// PassagesAuto splits PDFs into pages, Markdown into sections and
+	// other files into windows of PassageWords words.
+	PassagesAuto = "auto"
+	// PassagesWindows splits all files into windows of PassageWords words.
+	PassagesWindows = "windows"
+	// PassagesPages splits PDFs into pages and indexes other files as a whole.
+	PassagesPages = "pages"
+
+	defaultPassageWords = 200
+	// pageBreak separates the pages of text extracted from PDFs
+	pageBreak = '\f'
+)
+
+// PassageModes returns the accepted values of Options.Passages besides "".
+func PassageModes() []string {
+	return []string{PassagesAuto, PassagesWindows, PassagesPages}
+}
+
+// passage is a part of a file's text indexed as a document of its own.
+type passage struct {
+	// anchor identifies the passage in the file, e.g. "page-3"
+	anchor string
+	// label describes it to users, e.g. "page 3"
+	label string
+	// page is the PDF page number of page passages
+	page int
+	text []rune
+}
+
+// splitPassages splits the file's text into passages as configured by
+// Options.Passages. Files that don't split into at least two passages
+// are indexed as a whole and get none.
+func (i *Indexer) splitPassages(path string, content []rune) []passage {
+	var passages []passage
+	paged := i.extensions[strings.ToLower(filepath.Ext(path))] == "pdf" && containsRune(content, pageBreak)
+	switch i.opts.Passages {
+	case PassagesAuto:
+		switch {
+		case paged:
+			passages = pagePassages(content)
+		case isMarkdown(path):
+			passages = sectionPassages(content)
+		default:
+			passages = windowPassages(content, i.passageWords())
+		}
+	case PassagesWindows:
+		passages = windowPassages(content, i.passageWords())
+	case PassagesPages:
+		if paged {
+			passages = pagePassages(content)
+		}
+	}
+	if len(passages) < 2 {
+		return nil
+	}
+	return passages
+}
+
+func (i *Indexer) passageWords() int {
+	if i.opts.PassageWords > 0 {
+		return i.opts.PassageWords
+	}
+	return defaultPassageWords
+}
+
+func isMarkdown(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".md" || ext == ".markdown"
+}
+
+func containsRune(text []rune, r rune) bool {
+	for _, c := range text {
+		if c == r {
+			return true
+		}
+	}
+	return false
+}
+
+func isBlank(text []rune) bool {
+	for _, r := range text {
+		if !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// pagePassages splits text extracted from a PDF at page breaks,
+// skipping pages without text.
+func pagePassages(content []rune) []passage {
+	passages := make([]passage, 0)
+	page, start := 1, 0
+	for n := 0; n <= len(content); n++ {
+		if n < len(content) && content[n] != pageBreak {
+			continue
+		}
+		if text := content[start:n]; !isBlank(text) {
+			passages = append(passages, passage{
+				anchor: fmt.Sprintf("page-%d", page),
+				label:  fmt.Sprintf("page %d", page),
+				page:   page,
+				text:   text,
+			})
+		}
+		page++
+		start = n + 1
+	}
+	return passages
+}
+
+// sectionPassages splits Markdown at ATX headings ("# Title") outside of
+// fenced code blocks, the text before the first heading is a section too.
+func sectionPassages(content []rune) []passage {
+	passages := make([]passage, 0)
+	label, start := "introduction", 0
+	add := func(end int) {
+		if text := content[start:end]; !isBlank(text) {
+			passages = append(passages, passage{
+				anchor: fmt.Sprintf("section-%d", len(passages)+1),
+				label:  label,
+				text:   text,
+			})
+		}
+	}
+
+	fenced := false
+	for lineStart := 0; lineStart < len(content); {
+		lineEnd := lineStart
+		for lineEnd < len(content) && content[lineEnd] != '\n' {
+			lineEnd++
+		}
+		line := strings.TrimSpace(string(content[lineStart:lineEnd]))
+		switch {
+		case strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~"):
+			fenced = !fenced
+		case !fenced && lineStart > start && isHeading(line):
+			add(lineStart)
+			start = lineStart
+		}
+		if !fenced && lineStart == start && isHeading(line) {
+			label = strings.TrimSpace(strings.TrimLeft(line, "#"))
+		}
+		lineStart = lineEnd + 1
+	}
+	add(len(content))
+	return passages
+}
+
+// isHeading reports whether the trimmed line is a Markdown ATX heading.
+func isHeading(line string) bool {
+	level := len(line) - len(strings.TrimLeft(line, "#"))
+	if level == 0 || level > 6 {
+		return false
+	}
+	return len(line) == level || line[level] == ' ' || line[level] == '\t'
+}
+
+// windowPassages splits the text into consecutive windows of size words.
+func windowPassages(content []rune, size int) []passage {
+	passages := make([]passage, 0)
+	start, words := 0, 0
+	for n := 0; n < len(content); n++ {
+		if unicode.IsSpace(content[n]) || (n+1 < len(content) && !unicode.IsSpace(content[n+1])) {
+			continue
+		}
+		// n is the last rune of a word
+		words++
+		if words == size {
+			passages = appendWindow(passages, content[start:n+1])
+			start, words = n+1, 0
+		}
+	}
+	if words > 0 {
+		passages = appendWindow(passages, content[start:])
+	}
+	return passages
+}
+
+func appendWindow(passages []passage, text []rune) []passage {
+	number := len(passages) + 1
+	return append(passages, passage{
+		anchor: fmt.Sprintf("part-%d", number),
+		label:  fmt.Sprintf("part %d", number),
+		text:   text,
+	})
+}
+
+// groupPassages keeps the best ranked result of every file, recording the
+// other matching passages on it. The results must be sorted.
+func groupPassages(results []models.SearchQueryResult) []models.SearchQueryResult {
+	kept := make([]models.SearchQueryResult, 0, len(results))
+	position := make(map[string]int)
+	for _, result := range results {
+		if result.Anchor() == "" {
+			kept = append(kept, result)
+			continue
+		}
+		if n, seen := position[result.Path()]; seen {
+			if result.Rank() > 0 {
+				kept[n].AddMatch(result)
+			}
+			continue
+		}
+		position[result.Path()] = len(kept)
+		kept = append(kept, result)
+	}
+	return kept
+}
@@ -0,0 +1,205 @@
+package engine
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gfxv/scout/internal/models"
+)
+
+func TestSplitPassages(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		path    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "pdf pages skip empty ones",
+			mode:    PassagesAuto,
+			path:    "book.pdf",
+			content: "first page\f\f third page",
+			want:    []string{"page-1:page 1:first page", "page-3:page 3: third page"},
+		},
+		{
+			name:    "markdown sections",
+			mode:    PassagesAuto,
+			path:    "notes.md",
+			content: "intro\n# Setup\ninstall it\n```\n# not a heading\n```\n## Usage\nrun it",
+			want: []string{
+				"section-1:introduction:intro\n",
+				"section-2:Setup:# Setup\ninstall it\n```\n# not a heading\n```\n",
+				"section-3:Usage:## Usage\nrun it",
+			},
+		},
+		{
+			name:    "windows",
+			mode:    PassagesAuto,
+			path:    "notes.txt",
+			content: "one two three four five",
+			want:    []string{"part-1:part 1:one two", "part-2:part 2: three four", "part-3:part 3: five"},
+		},
+		{
+			name:    "windows of markdown",
+			mode:    PassagesWindows,
+			path:    "notes.md",
+			content: "# one two\nthree",
+			want:    []string{"part-1:part 1:# one", "part-2:part 2: two\nthree"},
+		},
+		{
+			name:    "pages only split pdfs",
+			mode:    PassagesPages,
+			path:    "book.pdf",
+			content: "first page\fsecond page",
+			want:    []string{"page-1:page 1:first page", "page-2:page 2:second page"},
+		},
+		{
+			name:    "pages leave other files whole",
+			mode:    PassagesPages,
+			path:    "notes.txt",
+			content: "one two three four five",
+			want:    []string{},
+		},
+		{
+			name:    "single passage",
+			mode:    PassagesAuto,
+			path:    "notes.md",
+			content: "# Title\nshort",
+			want:    []string{},
+		},
+		{
+			name:    "disabled",
+			mode:    "",
+			path:    "notes.txt",
+			content: "one two three four five",
+			want:    []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.Passages = tt.mode
+			opts.PassageWords = 2
+			indexer, err := NewIndexerWithOptions(newTestStore(), opts)
+			if err != nil {
+				t.Fatalf("NewIndexerWithOptions failed: %v", err)
+			}
+
+			got := make([]string, 0)
+			for _, passage := range indexer.splitPassages(tt.path, []rune(tt.content)) {
+				got = append(got, passage.anchor+":"+passage.label+":"+string(passage.text))
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGroupPassages(t *testing.T) {
+	page := func(number int, rank float32) models.SearchQueryResult {
+		path := fmt.Sprintf("book.pdf#page-%d", number)
+		docInfo := models.DocInfo{ID: number, Parent: "book.pdf", Passage: fmt.Sprintf("page %d", number), Page: number}
+		return models.NewSearchQueryResult(path, docInfo, rank)
+	}
+	results := []models.SearchQueryResult{
+		page(47, 0.9),
+		models.NewSearchQueryResult("notes.txt", models.DocInfo{ID: 100}, 0.5),
+		page(12, 0.4),
+		page(30, 0.2),
+		// not a match, not one of the matching pages
+		page(3, 0),
+	}
+
+	grouped := groupPassages(results)
+	if len(grouped) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(grouped))
+	}
+	book := grouped[0]
+	if book.Path() != "book.pdf" || book.ID() != 47 || book.Page() != 47 {
+		t.Errorf("expected page 47 of book.pdf, got page %d of %s", book.Page(), book.Path())
+	}
+	if pages := fmt.Sprint(book.Pages()); pages != "[12 30 47]" {
+		t.Errorf("expected pages [12 30 47], got %s", pages)
+	}
+	if matches := strings.Join(book.Matches(), ", "); matches != "page 47, page 12, page 30" {
+		t.Errorf("expected matches by rank, got %q", matches)
+	}
+}
+
+func TestIndexer_Passages(t *testing.T) {
+	files := map[string]string{
+		"guide.md": "# Install\ndownload the binary\n# Upgrade\nstop the service and replace the binary\n# Backup\ncopy the database before every upgrade",
+		"faq.txt":  "how do I upgrade",
+	}
+	dir := writeFiles(t, files)
+	opts := DefaultOptions()
+	opts.Passages = PassagesAuto
+	indexer, err := NewIndexerWithOptions(newTestStore(), opts)
+	if err != nil {
+		t.Fatalf("NewIndexerWithOptions failed: %v", err)
+	}
+	if err := indexer.IndexDir(models.DefaultCollection, dir); err != nil {
+		t.Fatalf("IndexDir failed: %v", err)
+	}
+	if err := indexer.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	guide := filepath.Join(dir, "guide.md")
+	response := indexer.Search("replace binary")
+	if len(response.Results) != 2 {
+		t.Fatalf("expected a result per file, got %d", len(response.Results))
+	}
+	best := response.Results[0]
+	if best.Path() != guide || best.Anchor() != "section-2" || best.Passage() != "Upgrade" {
+		t.Errorf("expected section Upgrade of %s first, got %q of %s", guide, best.Passage(), best.Path())
+	}
+	if matches := strings.Join(best.Matches(), ", "); matches != "Upgrade, Install" {
+		t.Errorf("expected matches in Upgrade, Install, got %q", matches)
+	}
+	if len(response.Facets.Types) != 1 || response.Facets.Types[0].Count != 1 {
+		t.Errorf("expected the file counted once by type, got %+v", response.Facets.Types)
+	}
+
+	view, err := indexer.DocumentView(best.ID(), "replace")
+	if err != nil {
+		t.Fatalf("DocumentView failed: %v", err)
+	}
+	if view.Path != guide || view.Anchor != "section-2" || len(view.Passages) != 3 {
+		t.Errorf("expected all 3 sections of %s, got %d of %s", guide, len(view.Passages), view.Path)
+	}
+	file, err := indexer.DocumentFile(best.ID())
+	if err != nil {
+		t.Fatalf("DocumentFile failed: %v", err)
+	}
+	if want, _ := resolvePath(guide); file != want {
+		t.Errorf("expected file %q, got %q", want, file)
+	}
+
+	collections, err := indexer.Collections()
+	if err != nil {
+		t.Fatalf("Collections failed: %v", err)
+	}
+	if collections[0].Documents != 2 {
+		t.Errorf("expected 2 documents, got %d", collections[0].Documents)
+	}
+
+	if _, err := indexer.Reanalyze(); err != nil {
+		t.Fatalf("Reanalyze failed: %v", err)
+	}
+	if results := indexer.SearchQuery("backup"); results[0].Anchor() != "section-3" {
+		t.Errorf("expected section-3 after Reanalyze, got %q", results[0].Anchor())
+	}
+
+	if err := indexer.RemoveFile(guide); err != nil {
+		t.Fatalf("RemoveFile failed: %v", err)
+	}
+	if len(indexer.documentIndex) != 1 {
+		t.Errorf("expected only faq.txt left, got %d documents", len(indexer.documentIndex))
+	}
+}
@@ -0,0 +1,248 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gfxv/scout/internal/models"
+)
+
+const maxFacetValues = 10
+
+// Query is a parsed search query: free text, wildcard patterns and filters
+// written as key:value, e.g. `kube* deploy type:pdf modified:>2025-01-01`.
+// Values containing spaces are quoted, e.g. `dir:"My Docs"`.
+type Query struct {
+	Text string
+	// Wildcards are patterns like kube* or f?o, matched against indexed terms
+	// once normalized by the analyzer of the searched collection.
+	Wildcards []string
+	// Types are lowercase extensions with a leading dot, any of them matches.
+	Types []string
+	// Dirs are directory paths, any of them matches, see matchesDir.
+	Dirs           []string
+	ModifiedAfter  time.Time
+	ModifiedBefore time.Time
+}
+
+// queryTerm is a term the documents are ranked by, expanded terms
+// (e.g. fuzzy matches) contribute less than the terms of the query.
+type queryTerm struct {
+	term   string
+	weight float32
+}
+
+var dateLayouts = []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02", "2006-01", "2006"}
+
+// ParseQuery extracts filters from the query, terms that look like filters
+// but can't be parsed are kept as text.
+func ParseQuery(raw string) Query {
+	var query Query
+	text := make([]string, 0)
+
+	for _, field := range queryFields(raw) {
+		key, value, ok := strings.Cut(field, ":")
+		if strings.HasPrefix(value, `"`) {
+			value = strings.TrimSuffix(value[1:], `"`)
+		}
+		if !ok || value == "" {
+			// full-width * and ? are wildcards too
+			if isWildcardPattern(string(normalize([]rune(field)))) {
+				query.Wildcards = append(query.Wildcards, field)
+			} else {
+				text = append(text, field)
+			}
+			continue
+		}
+
+		switch strings.ToLower(key) {
+		case "type", "ext":
+			ext := strings.ToLower(value)
+			if !strings.HasPrefix(ext, ".") {
+				ext = "." + ext
+			}
+			query.Types = append(query.Types, ext)
+		case "dir", "path":
+			query.Dirs = append(query.Dirs, strings.Trim(strings.ReplaceAll(value, "\\", "/"), "/"))
+		case "modified":
+			if err := query.parseModified(value); err != nil {
+				text = append(text, field)
+			}
+		default:
+			text = append(text, field)
+		}
+	}
+
+	query.Text = strings.Join(text, " ")
+	return query
+}
+
+// queryFields splits the query on whitespace like strings.Fields, except
+// inside a filter value in double quotes. An unterminated quote runs to the
+// end of the query.
+func queryFields(raw string) []string {
+	fields := make([]string, 0)
+	var field strings.Builder
+	quoted := false
+	for _, r := range raw {
+		switch {
+		case r == '"' && (quoted || strings.HasSuffix(field.String(), ":")):
+			quoted = !quoted
+			field.WriteRune(r)
+		case unicode.IsSpace(r) && !quoted:
+			if field.Len() > 0 {
+				fields = append(fields, field.String())
+				field.Reset()
+			}
+		default:
+			field.WriteRune(r)
+		}
+	}
+	if field.Len() > 0 {
+		fields = append(fields, field.String())
+	}
+	return fields
+}
+
+// parseModified accepts >date, >=date, <date, <=date and date,
+// where a bare date matches the whole day (or month, or year).
+func (q *Query) parseModified(value string) error {
+	op := strings.TrimRight(value[:min(2, len(value))], "0123456789")
+	date, layout, err := parseDate(strings.TrimPrefix(value, op))
+	if err != nil {
+		return err
+	}
+
+	switch op {
+	case ">":
+		q.ModifiedAfter = endOf(date, layout)
+	case ">=":
+		q.ModifiedAfter = date
+	case "<":
+		q.ModifiedBefore = date
+	case "<=":
+		q.ModifiedBefore = endOf(date, layout)
+	case "":
+		q.ModifiedAfter = date
+		q.ModifiedBefore = endOf(date, layout)
+	default:
+		return fmt.Errorf("unknown operator %q", op)
+	}
+	return nil
+}
+
+func parseDate(value string) (time.Time, string, error) {
+	for _, layout := range dateLayouts {
+		if date, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return date, layout, nil
+		}
+	}
+	return time.Time{}, "", fmt.Errorf("invalid date %q", value)
+}
+
+// endOf returns the start of the period after date, depending on its precision.
+func endOf(date time.Time, layout string) time.Time {
+	switch layout {
+	case "2006":
+		return date.AddDate(1, 0, 0)
+	case "2006-01":
+		return date.AddDate(0, 1, 0)
+	case "2006-01-02":
+		return date.AddDate(0, 0, 1)
+	case "2006-01-02T15:04":
+		return date.Add(time.Minute)
+	default:
+		return date.Add(time.Nanosecond)
+	}
+}
+
+func (q Query) matches(docInfo models.DocInfo) bool {
+	if len(q.Types) > 0 && !contains(q.Types, docInfo.Extension) {
+		return false
+	}
+	if len(q.Dirs) > 0 && !matchesAnyDir(q.Dirs, docInfo.Dir) {
+		return false
+	}
+	if !q.ModifiedAfter.IsZero() || !q.ModifiedBefore.IsZero() {
+		// unknown modification time never matches a date filter
+		if docInfo.ModTime.IsZero() {
+			return false
+		}
+		if !q.ModifiedAfter.IsZero() && docInfo.ModTime.Before(q.ModifiedAfter) {
+			return false
+		}
+		if !q.ModifiedBefore.IsZero() && !docInfo.ModTime.Before(q.ModifiedBefore) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAnyDir reports whether dir is one of dirs or below one of them.
+// Filters match on whole path segments anywhere in the path, so `dir:runbooks`
+// matches /home/me/docs/runbooks/db but not /home/me/docs/old-runbooks.
+func matchesAnyDir(dirs []string, dir string) bool {
+	padded := "/" + strings.Trim(dir, "/") + "/"
+	for _, filter := range dirs {
+		if strings.Contains(padded, "/"+filter+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// buildFacets counts types, directories and modification years of the results,
+// looking their documents up by ID as results of passages have their file's path.
+func buildFacets(docIndex models.DocIndex, docPaths map[int]string, results []models.SearchQueryResult) models.Facets {
+	types := make(map[string]int)
+	dirs := make(map[string]int)
+	years := make(map[string]int)
+
+	for _, result := range results {
+		docInfo := docIndex[docPaths[result.ID()]]
+		if docInfo.Extension != "" {
+			types[docInfo.Extension]++
+		}
+		if docInfo.Dir != "" {
+			dirs[docInfo.Dir]++
+		}
+		if !docInfo.ModTime.IsZero() {
+			years[docInfo.ModTime.Format("2006")]++
+		}
+	}
+
+	return models.Facets{
+		Types: topFacetValues(types),
+		Dirs:  topFacetValues(dirs),
+		Years: topFacetValues(years),
+	}
+}
+
+func topFacetValues(counts map[string]int) []models.FacetValue {
+	values := make([]models.FacetValue, 0, len(counts))
+	for value, count := range counts {
+		values = append(values, models.FacetValue{Value: value, Count: count})
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Count != values[j].Count {
+			return values[i].Count > values[j].Count
+		}
+		return values[i].Value < values[j].Value
+	})
+	if len(values) > maxFacetValues {
+		values = values[:maxFacetValues]
+	}
+	return values
+}
@@ -0,0 +1,173 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseQuery(t *testing.T) {
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 0, 0, 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		name     string
+		raw      string
+		expected Query
+	}{
+		{
+			name:     "text only",
+			raw:      "deploy rollback",
+			expected: Query{Text: "deploy rollback"},
+		},
+		{
+			name:     "type with and without dot",
+			raw:      "deploy type:PDF ext:.md",
+			expected: Query{Text: "deploy", Types: []string{".pdf", ".md"}},
+		},
+		{
+			name:     "dir",
+			raw:      "dir:/runbooks/db/ deploy",
+			expected: Query{Text: "deploy", Dirs: []string{"runbooks/db"}},
+		},
+		{
+			name:     "quoted dir",
+			raw:      `dir:"/home/me/My Docs" deploy path:"runbooks"`,
+			expected: Query{Text: "deploy", Dirs: []string{"home/me/My Docs", "runbooks"}},
+		},
+		{
+			name:     "unterminated quote",
+			raw:      `deploy dir:"My Docs`,
+			expected: Query{Text: "deploy", Dirs: []string{"My Docs"}},
+		},
+		{
+			name:     "quotes in text",
+			raw:      `"rolling upgrade" dir:""`,
+			expected: Query{Text: `"rolling upgrade" dir:""`},
+		},
+		{
+			name:     "modified after",
+			raw:      "deploy modified:>=2025-01-01",
+			expected: Query{Text: "deploy", ModifiedAfter: day(2025, 1, 1)},
+		},
+		{
+			name:     "modified before including the day",
+			raw:      "modified:<=2025-03-10",
+			expected: Query{ModifiedBefore: day(2025, 3, 11)},
+		},
+		{
+			name:     "modified after the year",
+			raw:      "modified:>2024",
+			expected: Query{ModifiedAfter: day(2025, 1, 1)},
+		},
+		{
+			name:     "modified after the month",
+			raw:      "modified:>2025-01",
+			expected: Query{ModifiedAfter: day(2025, 2, 1)},
+		},
+		{
+			name:     "modified year",
+			raw:      "modified:2024",
+			expected: Query{ModifiedAfter: day(2024, 1, 1), ModifiedBefore: day(2025, 1, 1)},
+		},
+		{
+			name:     "wildcards",
+			raw:      "Kube* deploy f?o what? *",
+			expected: Query{Text: "deploy what? *", Wildcards: []string{"Kube*", "f?o"}},
+		},
+		{
+			name:     "invalid filters are kept as text",
+			raw:      "modified:yesterday note: http://example.com",
+			expected: Query{Text: "modified:yesterday note: http://example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseQuery(tt.raw)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestIndexer_SearchFilters(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"runbooks/deploy.md":      "deploy the service",
+		"runbooks/old/deploy.txt": "deploy the old service",
+		"My Docs/deploy.md":       "deploy my notes",
+		"specs/deploy.md":         "deploy specification",
+	})
+	old := time.Date(2023, 6, 1, 12, 0, 0, 0, time.Local)
+	if err := os.Chtimes(filepath.Join(dir, "runbooks/old/deploy.txt"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	indexer, err := NewIndexerWithStore(newTestStore())
+	if err != nil {
+		t.Fatalf("NewIndexerWithStore failed: %v", err)
+	}
+	if err := indexer.IndexDir("default", dir); err != nil {
+		t.Fatalf("IndexDir failed: %v", err)
+	}
+	if err := indexer.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []string
+	}{
+		{
+			name:     "type",
+			query:    "deploy type:txt",
+			expected: []string{"runbooks/old/deploy.txt"},
+		},
+		{
+			name:     "dir includes subdirectories",
+			query:    "deploy dir:runbooks type:md",
+			expected: []string{"runbooks/deploy.md"},
+		},
+		{
+			name:     "quoted dir",
+			query:    `deploy dir:"My Docs"`,
+			expected: []string{"My Docs/deploy.md"},
+		},
+		{
+			name:     "modified",
+			query:    "deploy modified:<2024",
+			expected: []string{"runbooks/old/deploy.txt"},
+		},
+		{
+			name:     "no match",
+			query:    "deploy type:pdf",
+			expected: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]string, 0)
+			for _, result := range indexer.SearchQuery(tt.query) {
+				rel, _ := filepath.Rel(dir, result.Path())
+				got = append(got, filepath.ToSlash(rel))
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+
+	facets := indexer.Search("service").Facets
+	if len(facets.Types) != 2 || facets.Types[0].Value != ".md" || facets.Types[0].Count != 1 {
+		t.Errorf("expected .md (1) and .txt (1) type facets, got %+v", facets.Types)
+	}
+	if len(facets.Years) != 2 {
+		t.Errorf("expected two year facets, got %+v", facets.Years)
+	}
+}
@@ -6,11 +6,42 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
+	"strings"
 
 	"github.com/ledongthuc/pdf"
 	"golang.org/x/net/html"
 )
 
+type readerFunc func(path string) ([]rune, error)
+
+// readers by name, file extensions are mapped to these names
+var readers = map[string]readerFunc{
+	"text": plainTextReader,
+	"xml":  xmlReader,
+	"pdf":  pdfReader,
+	"html": htmlReader,
+}
+
+var defaultExtensions = map[string]string{
+	".md":    "text",
+	".txt":   "text",
+	".xml":   "xml",
+	".xhtml": "xml",
+	".pdf":   "pdf",
+	".html":  "html",
+}
+
+// ReaderNames returns the names extensions can be mapped to.
+func ReaderNames() []string {
+	names := make([]string, 0, len(readers))
+	for name := range readers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func plainTextReader(path string) ([]rune, error) {
 	content, err := os.ReadFile(path)
 	if err != nil {
@@ -19,6 +50,27 @@ func plainTextReader(path string) ([]rune, error) {
 	return bytes.Runes(content), nil
 }
 
+// PageError is a page of a PDF whose text couldn't be extracted.
+type PageError struct {
+	Page int
+	Err  error
+}
+
+// PageErrors is returned by pdfReader along with the text of the other
+// pages if some pages failed, it's a warning rather than a failure.
+type PageErrors struct {
+	Path  string
+	Pages []PageError
+}
+
+func (e *PageErrors) Error() string {
+	pages := make([]string, 0, len(e.Pages))
+	for _, page := range e.Pages {
+		pages = append(pages, fmt.Sprintf("page %d: %v", page.Page, page.Err))
+	}
+	return fmt.Sprintf("failed to extract text of %d page(s) of %s, %s", len(e.Pages), e.Path, strings.Join(pages, "; "))
+}
+
 func pdfReader(path string) ([]rune, error) {
 	file, reader, err := pdf.Open(path)
 	if err != nil {
@@ -26,24 +78,43 @@ func pdfReader(path string) ([]rune, error) {
 	}
 	defer file.Close()
 
-	// TODO: figure out how to estimate sclice len(cap) based on file size ???
-	content := make([]rune, 0)
+	content := make([]rune, 0, reader.NumPage())
+	failed := make([]PageError, 0)
 	for pageIndex := 1; pageIndex <= reader.NumPage(); pageIndex++ {
-		p := reader.Page(pageIndex)
-		if p.V.IsNull() {
-			continue
+		// pages are separated even if they have no text,
+		// so the page number of any text can be counted
+		if pageIndex > 1 {
+			content = append(content, pageBreak)
 		}
-
-		result, err := p.GetPlainText(nil)
+		text, err := pageText(reader, pageIndex)
 		if err != nil {
-			fmt.Println("failed to get plain text from page %d from document %s, err: %w", pageIndex, path, err)
+			failed = append(failed, PageError{Page: pageIndex, Err: err})
 			continue
 		}
-		content = append(content, []rune(result)...)
+		content = append(content, []rune(text)...)
+	}
+	if len(failed) > 0 {
+		return content, &PageErrors{Path: path, Pages: failed}
 	}
 	return content, nil
 }
 
+// pageText returns the plain text of the page, the pdf package panics on
+// some malformed pages, which only fails that page.
+func pageText(reader *pdf.Reader, pageIndex int) (text string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("malformed page: %v", r)
+		}
+	}()
+
+	p := reader.Page(pageIndex)
+	if p.V.IsNull() {
+		return "", nil
+	}
+	return p.GetPlainText(nil)
+}
+
 func xmlReader(path string) ([]rune, error) {
 	file, err := os.Open(path)
 	if err != nil {
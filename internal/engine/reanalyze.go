@@ -0,0 +1,114 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/gfxv/scout/internal/database"
+)
+
+// AnalyzerMismatch is a collection indexed with different analyzer
+// settings than the indexer has now.
+type AnalyzerMismatch struct {
+	Collection string
+	// Indexed and Current are analyzer fingerprints
+	Indexed string
+	Current string
+}
+
+// AnalyzerMismatches returns the collections whose terms were produced by
+// a different analyzer than the one their queries are analyzed with, such
+// collections need Reanalyze. Collections indexed before fingerprints were
+// recorded are skipped.
+func (i *Indexer) AnalyzerMismatches() ([]AnalyzerMismatch, error) {
+	collections, err := i.store.GetCollections()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load collections, err: %w", err)
+	}
+
+	mismatches := make([]AnalyzerMismatch, 0)
+	for _, collection := range collections {
+		current := i.analyzerFor(collection.Name).Fingerprint()
+		if collection.Analyzer != "" && collection.Analyzer != current {
+			mismatches = append(mismatches, AnalyzerMismatch{
+				Collection: collection.Name,
+				Indexed:    collection.Analyzer,
+				Current:    current,
+			})
+		}
+	}
+	return mismatches, nil
+}
+
+// Reanalyze rebuilds the terms of every indexed document with the current
+// analyzers, from the stored text if there is one and from the file otherwise.
+// Nothing changes unless the text of all documents can be read, and their
+// files still split into the same passages.
+// Returns the number of files that had to be read.
+func (i *Indexer) Reanalyze() (int, error) {
+	docIndex, _, err := i.store.LoadIndexData()
+	if err != nil {
+		return 0, fmt.Errorf("failed to load index, err: %w", err)
+	}
+
+	paths := make([]string, 0, len(docIndex))
+	for path := range docIndex {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	docs := make([]database.DocumentData, 0, len(paths))
+	fromFiles := 0
+	// passages of the file read last, by anchor
+	var file string
+	var passages map[string][]rune
+	for _, path := range paths {
+		docInfo := docIndex[path]
+		// passages of a file are sorted next to each other, it's read once
+		if docInfo.Parent == "" || docInfo.Parent != file {
+			content, stored, err := i.documentText(path, docInfo)
+			if err != nil {
+				return fromFiles, fmt.Errorf("failed to read text of %s, err: %w", path, err)
+			}
+			if !stored {
+				fmt.Printf("No stored text for %s, read the file\n", docInfo.FilePath(path))
+				fromFiles++
+			}
+			file = docInfo.FilePath(path)
+			passages = map[string][]rune{"": content}
+			if docInfo.Parent != "" {
+				for _, passage := range i.splitPassages(file, content) {
+					passages[passage.anchor] = passage.text
+				}
+			}
+		}
+
+		text, ok := passages[docInfo.Anchor(path)]
+		if !ok {
+			return fromFiles, fmt.Errorf("passages of %s changed since it was indexed, index it again", file)
+		}
+		analyzer := i.analyzerFor(docInfo.Collection)
+		docs = append(docs, database.DocumentData{
+			Collection: docInfo.Collection,
+			Path:       path,
+			Terms:      analyzer.Analyze(text),
+			Surfaces:   analyzer.SurfaceForms(text),
+		})
+	}
+
+	if err := i.store.ReplaceTerms(docs); err != nil {
+		return fromFiles, fmt.Errorf("failed to replace terms, err: %w", err)
+	}
+
+	collections, err := i.store.GetCollections()
+	if err != nil {
+		return fromFiles, fmt.Errorf("failed to load collections, err: %w", err)
+	}
+	for _, collection := range collections {
+		fingerprint := i.analyzerFor(collection.Name).Fingerprint()
+		if err := i.store.SaveCollection(collection.Name, collection.Root, fingerprint); err != nil {
+			return fromFiles, fmt.Errorf("failed to save collection %s, err: %w", collection.Name, err)
+		}
+	}
+	return fromFiles, i.Load()
+}
@@ -0,0 +1,85 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gfxv/scout/internal/models"
+)
+
+func TestIndexer_Reanalyze(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.md": "the rolling upgrade",
+		"b.md": "the cluster",
+	})
+	store := newTestStore()
+	opts := DefaultOptions()
+	opts.StoreText = true
+	indexer, err := NewIndexerWithOptions(store, opts)
+	if err != nil {
+		t.Fatalf("NewIndexerWithOptions failed: %v", err)
+	}
+	if err := indexer.IndexDir(models.DefaultCollection, dir); err != nil {
+		t.Fatalf("IndexDir failed: %v", err)
+	}
+
+	// the files are gone, only the stored text is left
+	for _, name := range []string{"a.md", "b.md"} {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts.Analyzer = AnalyzerConfig{Filters: []string{"lowercase", "stop"}}
+	reconfigured, err := NewIndexerWithOptions(store, opts)
+	if err != nil {
+		t.Fatalf("NewIndexerWithOptions failed: %v", err)
+	}
+	mismatches, err := reconfigured.AnalyzerMismatches()
+	if err != nil {
+		t.Fatalf("AnalyzerMismatches failed: %v", err)
+	}
+	if len(mismatches) != 1 || mismatches[0].Collection != models.DefaultCollection {
+		t.Fatalf("expected a mismatch in the default collection, got %+v", mismatches)
+	}
+
+	fromFiles, err := reconfigured.Reanalyze()
+	if err != nil {
+		t.Fatalf("Reanalyze failed: %v", err)
+	}
+	if fromFiles != 0 {
+		t.Errorf("expected all text to come from the store, %d documents were read from files", fromFiles)
+	}
+
+	mismatches, err = reconfigured.AnalyzerMismatches()
+	if err != nil {
+		t.Fatalf("AnalyzerMismatches failed: %v", err)
+	}
+	if len(mismatches) != 0 {
+		t.Errorf("expected no mismatches after Reanalyze, got %+v", mismatches)
+	}
+
+	// not stemmed anymore, and "the" is a stop word now
+	if got := reconfigured.docFrequency["rolling"]; got != 1 {
+		t.Errorf("expected doc frequency of \"rolling\" = 1, got %d", got)
+	}
+	if _, ok := reconfigured.docFrequency["roll"]; ok {
+		t.Error("expected stem \"roll\" to be replaced")
+	}
+	if _, ok := reconfigured.docFrequency["the"]; ok {
+		t.Error("expected stop word \"the\" to be dropped")
+	}
+}
+
+func TestAnalyzer_Fingerprint(t *testing.T) {
+	a, _ := NewAnalyzer(AnalyzerConfig{}, "english")
+	b, _ := NewAnalyzer(AnalyzerConfig{Tokenizer: "standard", Filters: []string{"lowercase", "stem"}}, "english")
+	c, _ := NewAnalyzer(AnalyzerConfig{}, "french")
+	if a.Fingerprint() != b.Fingerprint() {
+		t.Errorf("expected equal fingerprints for the default config, got %q and %q", a.Fingerprint(), b.Fingerprint())
+	}
+	if a.Fingerprint() == c.Fingerprint() {
+		t.Errorf("expected fingerprints to differ by language, got %q", a.Fingerprint())
+	}
+}
@@ -0,0 +1,102 @@
+package engine
+
+import (
+	"math"
+	"sort"
+
+	"github.com/gfxv/scout/internal/models"
+)
+
+// maxSimilarTerms is the number of a document's most characteristic
+// terms used to find documents similar to it.
+const maxSimilarTerms = 25
+
+// Similar ranks the documents of the given collections (or of all of them)
+// by cosine similarity to the document with the given ID. The document is
+// represented by its maxSimilarTerms terms with the highest tf-idf, only
+// documents sharing some of them are returned. Passages are compared
+// with passages of other files, the results are grouped by file.
+func (i *Indexer) Similar(id int, collections ...string) ([]models.SearchQueryResult, error) {
+	sourcePath, source, err := i.Document(id)
+	if err != nil {
+		return nil, err
+	}
+	sourceFile := source.FilePath(sourcePath)
+	scope := i.newSearchScope(collections)
+	fingerprint := i.analyzerFor(source.Collection).Fingerprint()
+
+	terms := i.topTerms(source, scope)
+	var queryNorm float64
+	for _, term := range terms {
+		queryNorm += float64(term.weight * term.weight)
+	}
+	queryNorm = math.Sqrt(queryNorm)
+
+	result := make([]models.SearchQueryResult, 0)
+	if queryNorm == 0 {
+		return result, nil
+	}
+	for path, docInfo := range i.documentIndex {
+		// terms of differently analyzed collections can't be compared,
+		// equally configured analyzers are separate instances
+		if docInfo.FilePath(path) == sourceFile || !scope.contains(docInfo.Collection) || i.analyzerFor(docInfo.Collection).Fingerprint() != fingerprint {
+			continue
+		}
+		var dot float64
+		for _, term := range terms {
+			if _, ok := docInfo.Terms[term.term]; ok {
+				dot += float64(term.weight * i.tf(term.term, docInfo) * i.idf(term.term, scope))
+			}
+		}
+		if dot <= 0 {
+			continue
+		}
+		similarity := float32(dot / (queryNorm * i.vectorNorm(docInfo, scope)))
+		if similarity < i.opts.MinScore {
+			continue
+		}
+		result = append(result, models.NewSearchQueryResult(path, docInfo, similarity))
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Rank() != result[j].Rank() {
+			return result[i].Rank() > result[j].Rank()
+		}
+		return result[i].Path() < result[j].Path()
+	})
+	result = groupPassages(result)
+	if i.opts.MaxResults > 0 && len(result) > i.opts.MaxResults {
+		result = result[:i.opts.MaxResults]
+	}
+	return result, nil
+}
+
+// topTerms returns the document's terms with the highest tf-idf, weighted by it.
+func (i *Indexer) topTerms(docInfo models.DocInfo, scope searchScope) []queryTerm {
+	terms := make([]queryTerm, 0, len(docInfo.Terms))
+	for term := range docInfo.Terms {
+		if weight := i.tf(term, docInfo) * i.idf(term, scope); weight > 0 {
+			terms = append(terms, queryTerm{term: term, weight: weight})
+		}
+	}
+	sort.Slice(terms, func(a, b int) bool {
+		if terms[a].weight != terms[b].weight {
+			return terms[a].weight > terms[b].weight
+		}
+		return terms[a].term < terms[b].term
+	})
+	if len(terms) > maxSimilarTerms {
+		terms = terms[:maxSimilarTerms]
+	}
+	return terms
+}
+
+// vectorNorm is the length of the document's tf-idf vector over all its terms.
+func (i *Indexer) vectorNorm(docInfo models.DocInfo, scope searchScope) float64 {
+	var norm float64
+	for term := range docInfo.Terms {
+		weight := float64(i.tf(term, docInfo) * i.idf(term, scope))
+		norm += weight * weight
+	}
+	return math.Sqrt(norm)
+}
@@ -0,0 +1,92 @@
+package engine
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestIndexer_Similar(t *testing.T) {
+	indexer, dir := newMemoryIndexer(t, map[string]string{
+		"upgrade.md":   "kubernetes cluster upgrade: drain the nodes, upgrade kubelet, uncordon the nodes",
+		"nodes.md":     "drain kubernetes nodes before the kubelet upgrade",
+		"cluster.md":   "the cluster runs kubernetes",
+		"cooking.md":   "boil the pasta and drain it",
+		"unrelated.md": "quarterly budget review",
+	})
+
+	var id int
+	for _, result := range indexer.SearchQuery("uncordon") {
+		if result.Rank() > 0 {
+			id = result.ID()
+		}
+	}
+	results, err := indexer.Similar(id)
+	if err != nil {
+		t.Fatalf("Similar failed: %v", err)
+	}
+
+	ranked := make([]string, 0)
+	for _, result := range results {
+		ranked = append(ranked, filepath.Base(result.Path()))
+		if result.Path() == filepath.Join(dir, "upgrade.md") {
+			t.Error("expected the document itself not to be returned")
+		}
+	}
+	if len(ranked) == 0 || ranked[0] != "nodes.md" {
+		t.Errorf("expected nodes.md to be the most similar, got %v", ranked)
+	}
+	for _, name := range ranked {
+		if name == "unrelated.md" {
+			t.Errorf("expected unrelated.md not to be returned, got %v", ranked)
+		}
+	}
+	for n := 1; n < len(results); n++ {
+		if results[n].Rank() > results[n-1].Rank() {
+			t.Errorf("expected results sorted by similarity, got %v", ranked)
+		}
+	}
+	if results[0].Rank() > 1 {
+		t.Errorf("expected cosine similarity of at most 1, got %v", results[0].Rank())
+	}
+
+	if _, err := indexer.Similar(-1); !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("expected ErrDocumentNotFound, got %v", err)
+	}
+}
+
+func TestIndexer_SimilarAcrossCollections(t *testing.T) {
+	runbooks := writeFiles(t, map[string]string{
+		"upgrade.md": "drain the kubernetes nodes and upgrade the kubelet",
+		"budget.md":  "quarterly budget review",
+	})
+	notes := writeFiles(t, map[string]string{"nodes.md": "drain kubernetes nodes before the kubelet upgrade"})
+	logs := writeFiles(t, map[string]string{"kubelet.md": "drain kubernetes nodes before the kubelet upgrade"})
+	opts := DefaultOptions()
+	opts.CollectionAnalyzers = map[string]AnalyzerConfig{
+		// the same as the default analyzer, a separate instance
+		"notes": {Filters: []string{"lowercase", "stem"}},
+		"logs":  {Tokenizer: "whitespace"},
+	}
+	indexer, err := NewIndexerWithOptions(newTestStore(), opts)
+	if err != nil {
+		t.Fatalf("NewIndexerWithOptions failed: %v", err)
+	}
+	for collection, root := range map[string]string{"runbooks": runbooks, "notes": notes, "logs": logs} {
+		if err := indexer.IndexDir(collection, root); err != nil {
+			t.Fatalf("IndexDir failed: %v", err)
+		}
+	}
+	if err := indexer.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	path := filepath.Join(runbooks, "upgrade.md")
+	results, err := indexer.Similar(indexer.documentIndex[path].ID)
+	if err != nil {
+		t.Fatalf("Similar failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Path() != filepath.Join(notes, "nodes.md") {
+		t.Errorf("expected nodes.md of the equally analyzed collection only, got %v", results)
+	}
+}
@@ -0,0 +1,76 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Source is a directory indexed into a collection. Include and Exclude hold
+// glob patterns (see filepath.Match): patterns without a '/' match file and
+// directory names anywhere below Root, patterns with a '/' match paths
+// relative to Root. A matching directory matches everything inside it.
+// If Include is empty, all files are included, Exclude always wins.
+type Source struct {
+	Collection string
+	Root       string
+	Include    []string
+	Exclude    []string
+}
+
+func (s Source) Validate() error {
+	if err := ValidateCollectionName(s.Collection); err != nil {
+		return err
+	}
+	info, err := os.Stat(s.Root)
+	if err != nil {
+		return fmt.Errorf("source %s: %w", s.Root, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("source %s is not a directory", s.Root)
+	}
+	for _, pattern := range append(append([]string{}, s.Include...), s.Exclude...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q in source %s: %w", pattern, s.Root, err)
+		}
+	}
+	return nil
+}
+
+// matches reports whether the file at path (below Root) should be indexed.
+func (s Source) matches(path string) bool {
+	rel, err := filepath.Rel(s.Root, path)
+	if err != nil {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+
+	if matchAny(s.Exclude, rel) {
+		return false
+	}
+	return len(s.Include) == 0 || matchAny(s.Include, rel)
+}
+
+// excludes reports whether the file at path (below Root) is excluded.
+func (s Source) excludes(path string) bool {
+	rel, err := filepath.Rel(s.Root, path)
+	return err != nil || matchAny(s.Exclude, filepath.ToSlash(rel))
+}
+
+// matchAny reports whether rel or any of its parent directories matches one of the patterns.
+func matchAny(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.TrimSuffix(pattern, "/")
+		for p := rel; p != "." && p != "/" && p != ""; p = filepath.ToSlash(filepath.Dir(p)) {
+			target := p
+			if !strings.Contains(pattern, "/") {
+				target = filepath.Base(p)
+			}
+			if ok, _ := filepath.Match(pattern, target); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSource_matches(t *testing.T) {
+	tests := []struct {
+		name     string
+		include  []string
+		exclude  []string
+		path     string
+		expected bool
+	}{
+		{name: "No rules", path: "a/b.md", expected: true},
+		{name: "Include by name", include: []string{"*.md"}, path: "a/b.md", expected: true},
+		{name: "Not included", include: []string{"*.md"}, path: "a/b.pdf", expected: false},
+		{name: "Exclude directory name", exclude: []string{"drafts"}, path: "specs/drafts/x.md", expected: false},
+		{name: "Exclude relative path", exclude: []string{"specs/*.pdf"}, path: "specs/x.pdf", expected: false},
+		{name: "Relative path only from root", exclude: []string{"specs/*.pdf"}, path: "old/specs/x.pdf", expected: true},
+		{name: "Exclude wins", include: []string{"*.md"}, exclude: []string{"README.md"}, path: "README.md", expected: false},
+		{name: "Include directory", include: []string{"runbooks/"}, path: "runbooks/db/restore.md", expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := Source{Root: "/docs", Include: tt.include, Exclude: tt.exclude}
+			if got := source.matches(filepath.Join("/docs", tt.path)); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestIndexer_Options(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.md":  "deploy deploy deploy",
+		"b.md":  "deploy the service",
+		"c.md":  "unrelated",
+		"d.rst": "deploy docs in restructured text",
+	})
+
+	opts := DefaultOptions()
+	opts.MaxResults = 2
+	opts.MinScore = 0.0001
+	opts.Extensions = map[string]string{".rst": "text"}
+	indexer, err := NewIndexerWithOptions(newTestStore(), opts)
+	if err != nil {
+		t.Fatalf("NewIndexerWithOptions failed: %v", err)
+	}
+	if err := indexer.IndexDir("", dir); err != nil {
+		t.Fatalf("IndexDir failed: %v", err)
+	}
+	if err := indexer.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if _, ok := indexer.documentIndex[filepath.Join(dir, "d.rst")]; !ok {
+		t.Error("expected .rst file to be indexed with the text reader")
+	}
+
+	results := indexer.SearchQuery("deploy")
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if results[0].Path() != filepath.Join(dir, "a.md") {
+		t.Errorf("expected a.md first, got %q", results[0].Path())
+	}
+
+	opts.Extensions = map[string]string{".rst": "docx"}
+	if _, err := NewIndexerWithOptions(newTestStore(), opts); err == nil {
+		t.Error("expected error for unknown reader")
+	}
+}
@@ -0,0 +1,128 @@
+package engine
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// synonyms maps a term to the terms searched along with it,
+// both analyzed the same way as indexed text.
+type synonyms map[string][]string
+
+// readSynonyms parses a synonyms file, one rule per line:
+//
+//	# equivalent terms, each one expands to all the others
+//	k8s, kubernetes
+//	db, database
+//	# one way, searching pg also searches postgres, but not the other way around
+//	pg => postgres
+//
+// Entries of several words are searched as all of their words,
+// but only single word entries are expanded.
+func readSynonyms(path string, analyzer *Analyzer) (synonyms, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open synonyms file, err: %w", err)
+	}
+	defer file.Close()
+
+	tokenize := func(text string) []string {
+		return analyzer.Analyze([]rune(text))
+	}
+	result, err := parseSynonyms(file, tokenize)
+	if err != nil {
+		return nil, fmt.Errorf("invalid synonyms file %s, %w", path, err)
+	}
+	return result, nil
+}
+
+func parseSynonyms(r io.Reader, tokenize func(string) []string) (synonyms, error) {
+	result := make(synonyms)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		rule, _, _ := strings.Cut(scanner.Text(), "#")
+		if strings.TrimSpace(rule) == "" {
+			continue
+		}
+
+		from, to, oneWay := strings.Cut(rule, "=>")
+		sources := splitSynonyms(from, tokenize)
+		targets := sources
+		if oneWay {
+			targets = splitSynonyms(to, tokenize)
+		}
+		if len(sources) == 0 || len(targets) == 0 || (!oneWay && len(sources) < 2) {
+			return nil, fmt.Errorf("line %d: expected `a, b` or `a => b`, got %q", line, scanner.Text())
+		}
+
+		for _, source := range sources {
+			if len(source) != 1 {
+				continue
+			}
+			for _, target := range targets {
+				for _, term := range target {
+					result.add(source[0], term)
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// splitSynonyms tokenizes the comma separated entries, dropping empty ones.
+func splitSynonyms(entries string, tokenize func(string) []string) [][]string {
+	result := make([][]string, 0)
+	for _, entry := range strings.Split(entries, ",") {
+		if tokens := tokenize(entry); len(tokens) > 0 {
+			result = append(result, tokens)
+		}
+	}
+	return result
+}
+
+func (s synonyms) add(term, synonym string) {
+	if term == synonym {
+		return
+	}
+	for _, existing := range s[term] {
+		if existing == synonym {
+			return
+		}
+	}
+	s[term] = append(s[term], synonym)
+}
+
+// ReloadSynonyms reads the synonyms file again, searches use the new
+// synonyms right away. Does nothing if no synonyms file is configured.
+func (i *Indexer) ReloadSynonyms() error {
+	if i.opts.SynonymsFile == "" {
+		return nil
+	}
+
+	// the synonyms have to be analyzed like the collections they expand terms of
+	byAnalyzer := make(map[*Analyzer]synonyms)
+	for _, analyzer := range i.allAnalyzers() {
+		synonyms, err := readSynonyms(i.opts.SynonymsFile, analyzer)
+		if err != nil {
+			return err
+		}
+		byAnalyzer[analyzer] = synonyms
+	}
+
+	i.synMu.Lock()
+	i.synonyms = byAnalyzer
+	i.synMu.Unlock()
+	return nil
+}
+
+func (i *Indexer) synonymsOf(term string, analyzer *Analyzer) []string {
+	i.synMu.Lock()
+	defer i.synMu.Unlock()
+	return i.synonyms[analyzer][term]
+}
@@ -0,0 +1,128 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSynonyms(t *testing.T) {
+	analyzer, err := NewAnalyzer(AnalyzerConfig{}, DefaultLanguage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokenize := func(text string) []string {
+		return analyzer.Analyze([]rune(text))
+	}
+
+	tests := []struct {
+		name     string
+		content  string
+		expected synonyms
+		wantErr  bool
+	}{
+		{
+			name:    "equivalent terms",
+			content: "# acronyms\nk8s, Kubernetes\n\ndb, database # short\n",
+			expected: synonyms{
+				"k8s":      {"kubernet"},
+				"kubernet": {"k8s"},
+				"db":       {"databas"},
+				"databas":  {"db"},
+			},
+		},
+		{
+			name:     "one way",
+			content:  "pg, psql => postgres",
+			expected: synonyms{"pg": {"postgr"}, "psql": {"postgr"}},
+		},
+		{
+			name:     "several words are only expanded to",
+			content:  "ci => continuous integration\nhello world, greeting",
+			expected: synonyms{"ci": {"continu", "integr"}, "greet": {"hello", "world"}},
+		},
+		{
+			name:    "single term",
+			content: "k8s",
+			wantErr: true,
+		},
+		{
+			name:    "empty target",
+			content: "k8s =>",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSynonyms(strings.NewReader(tt.content), tokenize)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error = %v, got %v", tt.wantErr, err)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestIndexer_SearchSynonyms(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"kube.md":  "kubernetes cluster upgrade",
+		"k8s.md":   "k8s cluster",
+		"other.md": "lunch menu",
+	})
+	synonymsFile := filepath.Join(t.TempDir(), "synonyms.txt")
+	if err := os.WriteFile(synonymsFile, []byte("lunch => food\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DefaultOptions()
+	opts.SynonymsFile = synonymsFile
+	indexer, err := NewIndexerWithOptions(newTestStore(), opts)
+	if err != nil {
+		t.Fatalf("NewIndexerWithOptions failed: %v", err)
+	}
+	if err := indexer.IndexDir("default", dir); err != nil {
+		t.Fatalf("IndexDir failed: %v", err)
+	}
+	if err := indexer.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	matches := func(query string) []string {
+		got := make([]string, 0)
+		for _, result := range indexer.SearchQuery(query) {
+			if result.Rank() > 0 {
+				got = append(got, filepath.Base(result.Path()))
+			}
+		}
+		return got
+	}
+
+	if got := matches("k8s"); !reflect.DeepEqual(got, []string{"k8s.md"}) {
+		t.Errorf("expected only k8s.md before synonyms are configured, got %q", got)
+	}
+
+	if err := os.WriteFile(synonymsFile, []byte("k8s, kubernetes\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := indexer.ReloadSynonyms(); err != nil {
+		t.Fatalf("ReloadSynonyms failed: %v", err)
+	}
+	if got := matches("k8s"); !reflect.DeepEqual(got, []string{"k8s.md", "kube.md"}) {
+		t.Errorf("expected the exact match ranked above the synonym, got %q", got)
+	}
+
+	if err := os.WriteFile(synonymsFile, []byte("broken\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := indexer.ReloadSynonyms(); err == nil {
+		t.Error("expected error for an invalid synonyms file")
+	}
+	if got := matches("kubernetes"); len(got) != 2 {
+		t.Errorf("expected previous synonyms to be kept after a failed reload, got %q", got)
+	}
+}
@@ -0,0 +1,164 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gfxv/scout/internal/database"
+	"github.com/gfxv/scout/internal/models"
+)
+
+// contentHash returns the hex encoded SHA-256 of the file.
+func contentHash(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("can't read file %s, err: %w", path, err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("can't read file %s, err: %w", path, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// extraction is the text of a file with its content hash.
+type extraction struct {
+	content []rune
+	hash    string
+	// stored is set if the text was already stored
+	stored bool
+	// noText is set if no text could be extracted, failedPages is the
+	// number of PDF pages without extracted text because of errors,
+	// see PageErrors. Both are kept with a stored text.
+	noText      bool
+	failedPages int
+}
+
+// extractText returns the text of the file along with its content hash.
+// A file with the same content as an already indexed one with stored text
+// isn't extracted again, it gets the stored text's extraction problems.
+func (i *Indexer) extractText(path string, reader readerFunc) (extraction, error) {
+	hash, err := contentHash(path)
+	if err != nil {
+		return extraction{}, err
+	}
+	text, err := i.store.GetStoredText(hash)
+	switch {
+	case err == nil:
+		return extraction{
+			content:     []rune(text.Text),
+			hash:        hash,
+			stored:      true,
+			noText:      text.NoText,
+			failedPages: text.FailedPages,
+		}, nil
+	case !errors.Is(err, database.ErrTextNotFound):
+		fmt.Printf("failed to load stored text of %s, err: %v\n", path, err)
+	}
+
+	content, failedPages, err := i.readText(path, reader)
+	return extraction{content: content, hash: hash, noText: isBlank(content), failedPages: failedPages}, err
+}
+
+// readText extracts the file's text with the reader. Pages failing to extract
+// are reported and counted, the text of the others is returned. If a PDF
+// can't be read, no text could be extracted from it or some of its pages
+// failed, the PDF fallback command is tried and its text used instead if
+// it has any.
+func (i *Indexer) readText(path string, reader readerFunc) ([]rune, int, error) {
+	content, err := reader(path)
+	failedPages := 0
+	var pageErrors *PageErrors
+	if errors.As(err, &pageErrors) {
+		fmt.Println(err)
+		failedPages, err = len(pageErrors.Pages), nil
+	}
+
+	if (err != nil || failedPages > 0 || isBlank(content)) && i.hasPDFFallback(path) {
+		text, fallbackErr := i.runner.run(i.opts.PDFFallback, path)
+		switch {
+		case fallbackErr != nil:
+			fmt.Println(fallbackErr)
+		case !isBlank(text):
+			return text, 0, nil
+		}
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return content, failedPages, nil
+}
+
+func (i *Indexer) hasPDFFallback(path string) bool {
+	return len(i.opts.PDFFallback) > 0 && i.extensions[strings.ToLower(filepath.Ext(path))] == "pdf"
+}
+
+// documentText returns the stored text of the document's file, or extracts it
+// from the file again if none is stored. stored reports where the text came from.
+func (i *Indexer) documentText(path string, docInfo models.DocInfo) (content []rune, stored bool, err error) {
+	path = docInfo.FilePath(path)
+	if docInfo.ContentHash != "" {
+		text, err := i.store.GetText(docInfo.ContentHash)
+		if err == nil {
+			return []rune(text), true, nil
+		}
+		if !errors.Is(err, database.ErrTextNotFound) {
+			return nil, false, fmt.Errorf("failed to load stored text of %s, err: %w", path, err)
+		}
+	}
+
+	reader, ok := i.readerFor(path)
+	if !ok {
+		return nil, false, fmt.Errorf("no reader for %s", path)
+	}
+	local, cleanup, err := i.localFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+	defer cleanup()
+	content, _, err = i.readText(local, reader)
+	return content, false, err
+}
+
+// ExtractionProblem is an indexed file whose text couldn't be extracted completely.
+type ExtractionProblem struct {
+	Path string
+	// NoText is set if no text at all was extracted, e.g. from a scanned PDF
+	NoText bool
+	// FailedPages is the number of PDF pages that failed to extract
+	FailedPages int
+}
+
+// ExtractionProblems returns the files no text was extracted from, followed
+// by those with pages that failed to extract, each sorted by path.
+func (i *Indexer) ExtractionProblems() []ExtractionProblem {
+	i.diMu.Lock()
+	defer i.diMu.Unlock()
+
+	problems := make([]ExtractionProblem, 0)
+	for path, docInfo := range fileIndex(i.documentIndex) {
+		if docInfo.NoText || docInfo.FailedPages > 0 {
+			problems = append(problems, ExtractionProblem{
+				Path:        path,
+				NoText:      docInfo.NoText,
+				FailedPages: docInfo.FailedPages,
+			})
+		}
+	}
+	sort.Slice(problems, func(a, b int) bool {
+		if problems[a].NoText != problems[b].NoText {
+			return problems[a].NoText
+		}
+		return problems[a].Path < problems[b].Path
+	})
+	return problems
+}
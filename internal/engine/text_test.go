@@ -0,0 +1,181 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gfxv/scout/internal/database"
+	"github.com/gfxv/scout/internal/models"
+)
+
+func TestIndexer_StoreText(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.md":    "rolling upgrade of the cluster",
+		"copy.md": "rolling upgrade of the cluster",
+	})
+	opts := DefaultOptions()
+	opts.StoreText = true
+	indexer, err := NewIndexerWithOptions(newTestStore(), opts)
+	if err != nil {
+		t.Fatalf("NewIndexerWithOptions failed: %v", err)
+	}
+	if err := indexer.IndexDir(models.DefaultCollection, dir); err != nil {
+		t.Fatalf("IndexDir failed: %v", err)
+	}
+	if err := indexer.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	results := indexer.SearchQuery("upgrade")
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	hash, err := contentHash(filepath.Join(dir, "a.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range results {
+		path, docInfo, err := indexer.Document(result.ID())
+		if err != nil {
+			t.Fatalf("Document failed: %v", err)
+		}
+		if docInfo.ContentHash != hash {
+			t.Errorf("expected content hash %q for %s, got %q", hash, path, docInfo.ContentHash)
+		}
+	}
+
+	// the view shows the indexed text, not what's on disk now
+	if err := os.WriteFile(filepath.Join(dir, "a.md"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range results {
+		view, err := indexer.DocumentView(result.ID(), "upgrade")
+		if err != nil {
+			t.Fatalf("DocumentView failed: %v", err)
+		}
+		text := ""
+		for _, passage := range view.Passages {
+			for _, segment := range passage.Segments {
+				text += segment.Text
+			}
+		}
+		if !strings.Contains(text, "rolling upgrade") {
+			t.Errorf("expected stored text for %s, got %q", view.Path, text)
+		}
+	}
+}
+
+func TestIndexer_StoredTextProblems(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"copy.md": "partly scanned report"})
+	hash, err := contentHash(filepath.Join(dir, "copy.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := newTestStore()
+	// a PDF with the same content was indexed with a page failing to extract
+	original := database.DocumentData{
+		Path:        filepath.Join(dir, "report.pdf"),
+		Terms:       []string{"report"},
+		ContentHash: hash,
+		Text:        "partly scanned report",
+		FailedPages: 1,
+	}
+	if err := store.AddDocuments([]database.DocumentData{original}); err != nil {
+		t.Fatalf("AddDocuments failed: %v", err)
+	}
+
+	indexer, err := NewIndexerWithStore(store)
+	if err != nil {
+		t.Fatalf("NewIndexerWithStore failed: %v", err)
+	}
+	if err := indexer.IndexFile(models.DefaultCollection, filepath.Join(dir, "copy.md")); err != nil {
+		t.Fatalf("IndexFile failed: %v", err)
+	}
+	indexer.Flush()
+	if err := indexer.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	problems := indexer.ExtractionProblems()
+	if len(problems) != 2 || problems[0].FailedPages != 1 || problems[1].FailedPages != 1 {
+		t.Errorf("expected both files with a failed page, got %+v", problems)
+	}
+}
+
+func TestIndexer_ExtractionProblems(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"empty.md":   " \n ",
+		"notes.md":   "meeting notes",
+		"broken.pdf": "not really a pdf",
+		"scan.pdf":   "scanned report",
+	})
+	tests := []struct {
+		name     string
+		fallback []string
+		// paths of the indexed documents without text
+		noText  []string
+		indexed int
+	}{
+		{name: "without fallback", noText: []string{"empty.md"}, indexed: 2},
+		// cat passes the PDFs' bytes through as their text
+		{name: "with fallback", fallback: []string{"cat", "{path}"}, noText: []string{"empty.md"}, indexed: 4},
+		{name: "failing fallback", fallback: []string{"false", "{path}"}, noText: []string{"empty.md"}, indexed: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.PDFFallback = tt.fallback
+			indexer, err := NewIndexerWithOptions(newTestStore(), opts)
+			if err != nil {
+				t.Fatalf("NewIndexerWithOptions failed: %v", err)
+			}
+			if err := indexer.IndexDir(models.DefaultCollection, dir); err != nil {
+				t.Fatalf("IndexDir failed: %v", err)
+			}
+			if err := indexer.Load(); err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+
+			if len(indexer.documentIndex) != tt.indexed {
+				t.Errorf("expected %d indexed documents, got %d", tt.indexed, len(indexer.documentIndex))
+			}
+			noText := make([]string, 0)
+			for _, problem := range indexer.ExtractionProblems() {
+				if problem.NoText {
+					noText = append(noText, filepath.Base(problem.Path))
+				}
+			}
+			if strings.Join(noText, ",") != strings.Join(tt.noText, ",") {
+				t.Errorf("expected %v without text, got %v", tt.noText, noText)
+			}
+			if tt.indexed == 4 && indexer.SearchQuery("scanned")[0].Path() != filepath.Join(dir, "scan.pdf") {
+				t.Errorf("expected the fallback text of scan.pdf to be searchable")
+			}
+		})
+	}
+}
+
+func TestOptions_PDFFallback(t *testing.T) {
+	tests := []struct {
+		fallback []string
+		valid    bool
+	}{
+		{fallback: []string{"cat", "{path}"}, valid: true},
+		{fallback: []string{"cat", "--", "{path}"}, valid: true},
+		{fallback: []string{"cat"}, valid: false},
+		// checked when indexing, see TestIndexer_MissingCommands
+		{fallback: []string{"scout-missing-extractor", "{path}"}, valid: true},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.fallback, " "), func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.PDFFallback = tt.fallback
+			if err := opts.Validate(); (err == nil) != tt.valid {
+				t.Errorf("expected valid %v, got %v", tt.valid, err)
+			}
+		})
+	}
+}
@@ -1,28 +1,86 @@
 package engine
 
 import (
+	"fmt"
+	"slices"
 	"strings"
 	"unicode"
 
 	"github.com/kljensen/snowball"
+	"golang.org/x/text/unicode/norm"
 )
 
+// DefaultLanguage is the stemming language used unless configured otherwise.
+const DefaultLanguage = "english"
+
+// SupportedLanguages are the languages snowball has stemmers for.
+var SupportedLanguages = []string{"english", "french", "hungarian", "norwegian", "russian", "spanish", "swedish"}
+
+func ValidateLanguage(language string) error {
+	if !slices.Contains(SupportedLanguages, language) {
+		return fmt.Errorf("unsupported language %q (available: %s)", language, strings.Join(SupportedLanguages, ", "))
+	}
+	return nil
+}
+
 type Tokenizer struct {
-	data []rune
+	data     []rune
+	language string
+	// pending are tokens already split off the data, e.g. CJK bigrams
+	pending []string
 }
 
 func NewTokenizer(data []rune) *Tokenizer {
+	return NewTokenizerWithLanguage(data, DefaultLanguage)
+}
+
+// NewTokenizerWithLanguage tokenizes the NFKC normalized data, so that
+// e.g. composed and decomposed "café" or "ﬁ" and "fi" give the same tokens.
+func NewTokenizerWithLanguage(data []rune, language string) *Tokenizer {
 	return &Tokenizer{
-		data: data,
+		data:     normalize(data),
+		language: language,
 	}
 }
 
+// NextToken returns the next token, tokens starting with a letter are
+// lowercased and words are stemmed.
 func (t *Tokenizer) NextToken() (string, bool) {
+	token, ok := t.nextRawToken()
+	if !ok || !startsWithLetter(token) {
+		return token, ok
+	}
+	token = strings.ToLower(token)
+	if !isWord(token) {
+		return token, true
+	}
+	return t.stem(token), true
+}
+
+// nextRawToken returns the next run of digits, run of letters and digits
+// starting with a letter, or any other single rune, as it is in the text.
+// Compound tokens like versions, IPs, emails, URLs and codes (see
+// compoundPatterns) are returned as a whole, followed by their parts.
+// Chinese, Japanese and Korean text is split into overlapping pairs of
+// characters (bigrams), as words aren't separated by spaces there.
+func (t *Tokenizer) nextRawToken() (string, bool) {
+	if len(t.pending) > 0 {
+		token := t.pending[0]
+		t.pending = t.pending[1:]
+		return token, true
+	}
+
 	t.trimLeftSpaces()
 	if len(t.data) == 0 {
 		return "", false
 	}
 
+	if n := compoundLength(t.data); n > 0 {
+		token := t.removeFirst(n)
+		t.pending = compoundParts(token)
+		return string(token), true
+	}
+
 	if unicode.IsDigit(t.data[0]) {
 		token := t.removeIf(func(r rune) bool {
 			return unicode.IsDigit(r)
@@ -30,17 +88,58 @@ func (t *Tokenizer) NextToken() (string, bool) {
 		return string(token), true
 	}
 
+	if isCJK(t.data[0]) {
+		t.pending = bigrams(t.removeIf(isCJK))
+		return t.nextRawToken()
+	}
+
 	if unicode.IsLetter(t.data[0]) {
-		token := t.removeIf(func(r rune) bool {
-			return unicode.IsLetter(r) || unicode.IsDigit(r)
-		})
-		stemmed := t.stem(strings.ToLower(string(token)))
-		return stemmed, true
+		// marks keep letters of scripts like Devanagari together
+		token := t.removeIf(isAlphanumeric)
+		return string(token), true
 	}
 
 	return string(t.removeFirst(1)), true
 }
 
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// bigrams returns the overlapping pairs of runes, or the single rune.
+func bigrams(run []rune) []string {
+	if len(run) == 1 {
+		return []string{string(run)}
+	}
+	tokens := make([]string, 0, len(run)-1)
+	for n := 0; n+1 < len(run); n++ {
+		tokens = append(tokens, string(run[n:n+2]))
+	}
+	return tokens
+}
+
+func normalize(text []rune) []rune {
+	return []rune(norm.NFKC.String(string(text)))
+}
+
+// foldDiacritics removes accents and other marks, e.g. "Crème brûlée" becomes "Creme brulee".
+func foldDiacritics(text []rune) []rune {
+	folded := make([]rune, 0, len(text))
+	for _, r := range norm.NFD.String(string(text)) {
+		if !unicode.Is(unicode.Mn, r) {
+			folded = append(folded, r)
+		}
+	}
+	return []rune(norm.NFC.String(string(folded)))
+}
+
+func startsWithLetter(token string) bool {
+	for _, r := range token {
+		return unicode.IsLetter(r)
+	}
+	return false
+}
+
 func (t *Tokenizer) trimLeftSpaces() {
 	for len(t.data) > 0 && unicode.IsSpace(t.data[0]) {
 		t.data = t.data[1:]
@@ -70,6 +169,9 @@ func (t *Tokenizer) stem(token string) string {
 	// like which, and, why, with, but, etc. (acutally doesn't really matter,
 	// because tf-idf handles these words quite good).
 	// error may happen only if the language is unknown for the library
-	stemmed, _ := snowball.Stem(token, "english", true) // TODO: support different languages
+	stemmed, err := snowball.Stem(token, t.language, true)
+	if err != nil {
+		return token
+	}
 	return stemmed
 }
@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"slices"
 	"testing"
 	"unicode"
 )
@@ -197,3 +198,165 @@ func TestTokenizer_NextToken(t *testing.T) {
 		})
 	}
 }
+
+func TestTokenizer_Unicode(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected []string
+	}{
+		{
+			name:     "NFC and NFD give the same token",
+			content:  "caf\u00e9 cafe\u0301",
+			expected: []string{"caf\u00e9", "caf\u00e9"},
+		},
+		{
+			name:     "Compatibility characters are normalized",
+			content:  "ﬁle ＡＢＣ ①",
+			expected: []string{"file", "abc", "1"},
+		},
+		{
+			name:     "Combining marks stay in the word",
+			content:  "हिन्दी",
+			expected: []string{"हिन्दी"},
+		},
+		{
+			name:     "Chinese is split into bigrams",
+			content:  "东京大学",
+			expected: []string{"东京", "京大", "大学"},
+		},
+		{
+			name:     "Single CJK character",
+			content:  "猫",
+			expected: []string{"猫"},
+		},
+		{
+			name:     "Japanese mixed with latin and digits",
+			content:  "Go言語は2009年",
+			expected: []string{"go", "言語", "語は", "2009", "年"},
+		},
+		{
+			name:     "Korean",
+			content:  "한국어 검색",
+			expected: []string{"한국", "국어", "검색"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokenizer := NewTokenizer([]rune(tt.content))
+			result := make([]string, 0)
+			for token, ok := tokenizer.NextToken(); ok; token, ok = tokenizer.NextToken() {
+				result = append(result, token)
+			}
+			if !slices.Equal(result, tt.expected) {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestTokenizer_foldDiacritics(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{
+			name:     "Accents",
+			content:  "Crème brûlée à la café",
+			expected: "Creme brulee a la cafe",
+		},
+		{
+			name:     "Decomposed input",
+			content:  "cafe\u0301",
+			expected: "cafe",
+		},
+		{
+			name:     "Other scripts",
+			content:  "Ελληνικά ёлка",
+			expected: "Ελληνικα елка",
+		},
+		{
+			name:     "No diacritics",
+			content:  "plain ascii ß",
+			expected: "plain ascii ß",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := string(foldDiacritics([]rune(tt.content)))
+			if result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestTokenizer_Compound(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected []string
+	}{
+		{
+			name:     "Decimal",
+			content:  "pi is 3.14.",
+			expected: []string{"pi", "is", "3.14", "3", "14", "."},
+		},
+		{
+			name:     "Version",
+			content:  "go v1.23.3",
+			expected: []string{"go", "v1.23.3", "v1", "23", "3"},
+		},
+		{
+			name:     "Pre-release version",
+			content:  "2.0.0-rc.1",
+			expected: []string{"2.0.0-rc.1", "2", "0", "0", "rc", "1"},
+		},
+		{
+			name:     "IP address",
+			content:  "ping 192.168.0.1",
+			expected: []string{"ping", "192.168.0.1", "192", "168", "0", "1"},
+		},
+		{
+			name:     "Email",
+			content:  "mail John.Doe@Example.com, please",
+			expected: []string{"mail", "john.doe@example.com", "john", "doe", "exampl", "com", ",", "pleas"},
+		},
+		{
+			name:     "URL",
+			content:  "see https://example.com/docs?page=2).",
+			expected: []string{"see", "https://example.com/docs?page=2", "https", "exampl", "com", "doc", "page", "2", ")", "."},
+		},
+		{
+			name:     "Hyphenated code",
+			content:  "got ERR-4012 again",
+			expected: []string{"got", "err-4012", "err", "4012", "again"},
+		},
+		{
+			name:     "Identifier",
+			content:  "set max_file_size",
+			expected: []string{"set", "max_file_size", "max", "file", "size"},
+		},
+		{
+			name:     "Hyphenated words are split",
+			content:  "well-known e.g.",
+			expected: []string{"well", "-", "known", "e", ".", "g", "."},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokenizer := NewTokenizer([]rune(tt.content))
+			result := make([]string, 0)
+			for token, ok := tokenizer.NextToken(); ok; token, ok = tokenizer.NextToken() {
+				result = append(result, token)
+			}
+			if !slices.Equal(result, tt.expected) {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
@@ -1,16 +1,64 @@
 package models
 
+import "time"
+
 type Document struct {
 	ID         int    `gorm:"primaryKey"`
+	Collection string `gorm:"not null;default:default;index"`
 	Path       string `gorm:"unique;index"`
-	TotalTerms uint
-	Terms      []*Term `gorm:"many2many:document_terms;"`
+	Extension  string `gorm:"index"`
+	Dir        string `gorm:"index"`
+	ModTime    time.Time
+	// ContentHash is the SHA-256 of the file
+	ContentHash string `gorm:"index"`
+	// SimHash is a signature of the text, similar texts have signatures
+	// differing in few bits, 0 if unknown
+	SimHash int64
+	// Parent is the path of the file a passage was split from,
+	// empty for files indexed as a whole
+	Parent  string `gorm:"index"`
+	Passage string
+	// Page is the PDF page number of a page passage, 0 otherwise
+	Page int
+	// NoText marks files no text could be extracted from, e.g. scanned
+	// PDFs, FailedPages counts the PDF pages that failed to extract
+	NoText      bool
+	FailedPages int
+	TotalTerms  uint
+	Terms       []*Term `gorm:"many2many:document_terms;"`
+}
+
+// DocumentText is the compressed text extracted from files with the given
+// content hash, shared by all documents with the same content.
+type DocumentText struct {
+	Hash string `gorm:"primaryKey"`
+	Data []byte
+	// Size is the length of the uncompressed text in bytes
+	Size int
+	// NoText and FailedPages are the problems extracting the text, see Document
+	NoText      bool
+	FailedPages int
 }
 
 type Term struct {
 	ID       int    `gorm:"primaryKey"`
-	Text     string `gorm:"unique;index;collate:NOCASE"`
+	Text     string `gorm:"unique;index"`
 	DocCount uint
+	// Surface is a word the term was analyzed from, shown to users
+	// instead of the term, e.g. "kubernetes" for "kubernet"
+	Surface string `gorm:"not null;default:''"`
+}
+
+// BetterSurfaceForm reports whether word is a better surface form of term
+// than form: a word equal to its term is preferred, otherwise the shortest.
+func BetterSurfaceForm(term, word, form string) bool {
+	if (word == term) != (form == term) {
+		return word == term
+	}
+	if len(word) != len(form) {
+		return len(word) < len(form)
+	}
+	return word < form
 }
 
 type DocumentTerm struct {
@@ -18,3 +66,32 @@ type DocumentTerm struct {
 	TermID     int `gorm:"primaryKey"`
 	Count      uint
 }
+
+type Collection struct {
+	ID            int    `gorm:"primaryKey"`
+	Name          string `gorm:"unique;not null"`
+	Root          string
+	LastIndexedAt time.Time
+	// Analyzer is the fingerprint of the analyzer the collection was indexed with
+	Analyzer string
+}
+
+// CollectionRoot is a directory a collection was indexed from, a collection
+// fed by several sources has several.
+type CollectionRoot struct {
+	ID         int    `gorm:"primaryKey"`
+	Collection string `gorm:"not null;uniqueIndex:idx_collection_root"`
+	Root       string `gorm:"not null;uniqueIndex:idx_collection_root"`
+}
+
+// SchemaVersion records every migration applied to the database,
+// the current schema version is the highest Version.
+type SchemaVersion struct {
+	Version   int `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+func (SchemaVersion) TableName() string {
+	return "schema_version"
+}
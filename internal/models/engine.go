@@ -1,23 +1,219 @@
 package models
 
+import (
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultCollection is used when no collection is specified while indexing.
+const DefaultCollection = "default"
+
 type TermFreq map[string]uint
 
 type DocInfo struct {
+	// ID is the store's document ID, used in URLs
+	ID         int
+	Collection string
+	// ContentHash is the SHA-256 of the file, its text is stored under it
+	// if it was indexed with StoreText
+	ContentHash string
+	// SimHash is a signature of the text for near-duplicate detection
+	SimHash uint64
+	// Parent is the path of the file a passage was split from, empty for
+	// files indexed as a whole. The passage's own path is Parent#anchor.
+	Parent string
+	// Passage describes where the passage is in its file, e.g. "page 3"
+	Passage string
+	// Page is the PDF page number of a page passage, 0 otherwise
+	Page int
+	// NoText marks files no text could be extracted from, e.g. scanned PDFs
+	NoText bool
+	// FailedPages is the number of the file's PDF pages that failed to extract
+	FailedPages int
+	// Extension is lowercase and includes the dot, e.g. ".pdf"
+	Extension string
+	// Dir is the parent directory, with forward slashes
+	Dir        string
+	ModTime    time.Time
 	Terms      TermFreq
 	TotalTerms uint
 }
 
+// FilePath returns the path of the file the document at path comes from,
+// which is the path itself unless the document is a passage.
+func (d DocInfo) FilePath(path string) string {
+	if d.Parent != "" {
+		return d.Parent
+	}
+	return path
+}
+
+// Anchor returns the passage's anchor in its file, e.g. "page-3",
+// empty unless the document at path is a passage.
+func (d DocInfo) Anchor(path string) string {
+	if d.Parent == "" {
+		return ""
+	}
+	return strings.TrimPrefix(path, d.Parent+"#")
+}
+
 type DocIndex map[string]DocInfo
 
+type CollectionStats struct {
+	Name string
+	// Root is the directory the collection was last indexed from
+	Root string
+	// Roots are all directories the collection was indexed from
+	Roots         []string
+	Documents     int64
+	LastIndexedAt time.Time
+	// Analyzer is the fingerprint of the analyzer the collection was
+	// indexed with, empty if it was indexed before fingerprints existed
+	Analyzer string
+}
+
+// FacetValue is the number of results sharing a value, e.g. 12 results of type ".pdf".
+type FacetValue struct {
+	Value string
+	Count int
+}
+
+type Facets struct {
+	Types []FacetValue
+	Dirs  []FacetValue
+	Years []FacetValue
+}
+
+type SearchResponse struct {
+	Results []SearchQueryResult
+	// Facets are counted over all matching results, before MaxResults is applied.
+	Facets Facets
+	// Suggestion is the query with misspelt terms corrected, empty if there were none.
+	Suggestion string
+}
+
+// HasMatches reports whether any result matched the search terms.
+func (r SearchResponse) HasMatches() bool {
+	return len(r.Results) > 0 && r.Results[0].Rank() > 0
+}
+
+// TextSegment is a piece of a document's text, highlighted if it matches the query.
+type TextSegment struct {
+	Text      string
+	Highlight bool
+}
+
+// PassageView is a passage of a document's text, split into segments.
+// Documents indexed as a whole have a single passage without anchor.
+type PassageView struct {
+	Anchor string
+	Label  string
+	// Page is the PDF page number of a page passage, 0 otherwise
+	Page     int
+	Segments []TextSegment
+}
+
+// DocumentView is the extracted text of a document, split into passages.
+type DocumentView struct {
+	ID         int
+	Path       string
+	Collection string
+	// Anchor is the anchor of the viewed passage, if it is one
+	Anchor string
+	// Page is the PDF page number of the viewed passage, if it is a page
+	Page     int
+	Passages []PassageView
+	// Truncated is set if the text was too long to show completely
+	Truncated bool
+}
+
 type SearchQueryResult struct {
+	id   int
 	path string
-	rank float32
+	// duplicates is the number of duplicates collapsed into the result
+	duplicates int
+	// anchor, passage and page identify the best matching passage of the
+	// file, matches are the labels of all its matching passages, best
+	// first, and pages the numbers of the matching PDF pages
+	anchor     string
+	passage    string
+	page       int
+	matches    []string
+	pages      []int
+	collection string
+	modTime    time.Time
+	rank       float32
 }
 
-func NewSearchQueryResult(path string, rank float32) SearchQueryResult {
-	return SearchQueryResult{
-		path: path,
-		rank: rank,
+// NewSearchQueryResult creates the result for the document at path,
+// a passage's result is for its file.
+func NewSearchQueryResult(path string, docInfo DocInfo, rank float32) SearchQueryResult {
+	result := SearchQueryResult{
+		id:         docInfo.ID,
+		path:       docInfo.FilePath(path),
+		anchor:     docInfo.Anchor(path),
+		passage:    docInfo.Passage,
+		page:       docInfo.Page,
+		collection: docInfo.Collection,
+		modTime:    docInfo.ModTime,
+		rank:       rank,
+	}
+	if docInfo.Passage != "" && rank > 0 {
+		result.matches = []string{docInfo.Passage}
+	}
+	if docInfo.Page > 0 && rank > 0 {
+		result.pages = []int{docInfo.Page}
+	}
+	return result
+}
+
+func (s *SearchQueryResult) ID() int {
+	return s.id
+}
+
+// Duplicates is the number of duplicates of the document left out of the results.
+func (s *SearchQueryResult) Duplicates() int {
+	return s.duplicates
+}
+
+func (s *SearchQueryResult) AddDuplicate() {
+	s.duplicates++
+}
+
+// Anchor is the anchor of the best matching passage, empty unless
+// the file was split into passages.
+func (s *SearchQueryResult) Anchor() string {
+	return s.anchor
+}
+
+// Passage is the label of the best matching passage, e.g. "page 3".
+func (s *SearchQueryResult) Passage() string {
+	return s.passage
+}
+
+// Page is the PDF page number of the best matching passage, 0 if it isn't a page.
+func (s *SearchQueryResult) Page() int {
+	return s.page
+}
+
+// Matches are the labels of the file's passages matching the query, best first.
+func (s *SearchQueryResult) Matches() []string {
+	return s.matches
+}
+
+// Pages are the numbers of the file's PDF pages matching the query, in order.
+func (s *SearchQueryResult) Pages() []int {
+	return s.pages
+}
+
+// AddMatch records another matching passage of the same file.
+func (s *SearchQueryResult) AddMatch(other SearchQueryResult) {
+	s.matches = append(s.matches, other.matches...)
+	for _, page := range other.pages {
+		n := sort.SearchInts(s.pages, page)
+		s.pages = slices.Insert(s.pages, n, page)
 	}
 }
 
@@ -25,6 +221,15 @@ func (s *SearchQueryResult) Path() string {
 	return s.path
 }
 
+func (s *SearchQueryResult) Collection() string {
+	return s.collection
+}
+
+// ModTime is the zero time if unknown (documents indexed before it was stored).
+func (s *SearchQueryResult) ModTime() time.Time {
+	return s.modTime
+}
+
 func (s *SearchQueryResult) Rank() float32 {
 	return s.rank
 }